@@ -0,0 +1,55 @@
+package fsm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddCallbackTyped adds a callback for a state that expects args of the given types.
+// Transition to the state and Refire check the args before the state is changed
+// and return ErrArgMismatch if their count or types don't match
+func (f *FSM[K, V]) AddCallbackTyped(stateID StateID, argTypes []reflect.Type, callback Callback) {
+	f.cbMu.Lock()
+	defer f.cbMu.Unlock()
+
+	f.addCallback(stateID, callback)
+	if callback != nil {
+		f.argTypes[stateID] = argTypes
+	}
+}
+
+// checkArgs checks the args against the types registered by AddCallbackTyped for the state
+func (f *FSM[K, V]) checkArgs(stateID StateID, args []any) error {
+	f.cbMu.RLock()
+	argTypes, ok := f.argTypes[stateID]
+	f.cbMu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if len(args) != len(argTypes) {
+		return fmt.Errorf("%w: expected %d args, got %d", ErrArgMismatch, len(argTypes), len(args))
+	}
+
+	for i, arg := range args {
+		if !assignable(arg, argTypes[i]) {
+			return fmt.Errorf("%w: arg %d: expected %s, got %T", ErrArgMismatch, i, argTypes[i], arg)
+		}
+	}
+
+	return nil
+}
+
+// assignable reports whether the arg can be asserted to the type, a nil arg matches nillable types
+func assignable(arg any, t reflect.Type) bool {
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return true
+		default:
+			return false
+		}
+	}
+
+	return reflect.TypeOf(arg).AssignableTo(t)
+}
@@ -0,0 +1,24 @@
+package fsm
+
+import "fmt"
+
+// Authorizer is a function deciding whether the user may enter a protected state
+type Authorizer func(userID int64) (bool, error)
+
+// authorize checks the user against the authorizer of the state set by WithAuthorizedStates
+func (f *FSM[K, V]) authorize(userID int64, stateID StateID) error {
+	authorizer, ok := f.authorizers[stateID]
+	if !ok {
+		return nil
+	}
+
+	allowed, err := authorizer(userID)
+	if err != nil {
+		return fmt.Errorf("failed to authorize user: %w", err)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: state: %s", ErrUnauthorized, stateID)
+	}
+
+	return nil
+}
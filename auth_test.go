@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuthorizedStates(t *testing.T) {
+	ctx := context.Background()
+
+	var entered []int64
+	f := New[string, int]("start", map[StateID]Callback{
+		"admin": func(ctx context.Context, _ ...any) error {
+			userID, _ := UserIDFromContext(ctx)
+			entered = append(entered, userID)
+			return nil
+		},
+	}, WithAuthorizedStates[string, int](map[StateID]Authorizer{
+		"admin": func(userID int64) (bool, error) { return userID == 1, nil },
+	}))
+	for _, userID := range []int64{1, 2} {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.Transition(ctx, 1, "admin"); err != nil {
+		t.Fatalf("expected the authorized user to enter, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "admin" {
+		t.Fatalf("expected state admin, got %s", got)
+	}
+
+	err := f.Transition(ctx, 2, "admin")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+	if got := mustState(t, f, 2); got != "start" {
+		t.Fatalf("expected the unauthorized user to stay in start, got %s", got)
+	}
+	if len(entered) != 1 || entered[0] != 1 {
+		t.Fatalf("expected only the authorized user's callback, got %v", entered)
+	}
+}
+
+func TestAuthorizerError(t *testing.T) {
+	f := New[string, int]("start", nil, WithAuthorizedStates[string, int](map[StateID]Authorizer{
+		"admin": func(int64) (bool, error) { return false, errTest },
+	}))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(context.Background(), 1, "admin")
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected authorizer error, got %v", err)
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected authorizer error not to be ErrUnauthorized, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "start" {
+		t.Fatalf("expected state start, got %s", got)
+	}
+}
+
+func TestStateInfoAuthorizedAndDeferred(t *testing.T) {
+	f := New[string, int]("start", nil, WithAuthorizedStates[string, int](map[StateID]Authorizer{
+		"admin": func(int64) (bool, error) { return true, nil },
+	}))
+	f.AddDeferredCallback("report", noop)
+
+	if info := f.StateInfo("admin"); !info.Authorized || info.Deferred {
+		t.Fatalf("expected admin to be authorized only, got %+v", info)
+	}
+	if info := f.StateInfo("report"); info.Authorized || !info.Deferred {
+		t.Fatalf("expected report to be deferred only, got %+v", info)
+	}
+}
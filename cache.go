@@ -0,0 +1,585 @@
+package fsm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CacheConfig is a configuration of caching storage decorators
+type CacheConfig struct {
+	// Size is a maximum number of cached entries, 0 means unbounded
+	Size int
+	// TTL is a time after which a cached entry is read from the backend again, 0 means no expiration
+	TTL time.Duration
+	// Clock is a clock for entries expiration, the system clock is used if nil
+	Clock Clock
+}
+
+// lruCache is a bounded LRU cache with expiration.
+// Values read from the backend are filled only if the key wasn't written while the read was in flight,
+// so a slow read can't overwrite a newer write with a stale value
+type lruCache[K comparable, V any] struct {
+	mu     sync.Mutex
+	config CacheConfig
+	items  map[K]*list.Element
+	order  *list.List
+	// epoch is the number of writes, reads remember the epoch they started at
+	epoch uint64
+	// reads counts in-flight reads by their start epoch
+	reads map[uint64]int
+	// written and removed keep the writes made while reads are in flight
+	written map[K]uint64
+	removed []cacheRemoval[K]
+}
+
+// cacheRemoval is a removal of the entries matching the predicate made at the epoch
+type cacheRemoval[K comparable] struct {
+	match func(key K) bool
+	epoch uint64
+}
+
+// lruEntry is an entry of lruCache
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// newLRUCache creates an LRU cache
+func newLRUCache[K comparable, V any](config CacheConfig) *lruCache[K, V] {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	return &lruCache[K, V]{
+		config:  config,
+		items:   make(map[K]*list.Element),
+		order:   list.New(),
+		reads:   make(map[uint64]int),
+		written: make(map[K]uint64),
+	}
+}
+
+// get returns a cached value, it returns false if there is no such entry or it has expired
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		var empty V
+		return empty, false
+	}
+
+	entry := el.Value.(*lruEntry[K, V])
+	if c.config.TTL > 0 && !c.config.Clock.Now().Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+
+		var empty V
+		return empty, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return entry.value, true
+}
+
+// put caches a written value evicting the least recently used entry if the cache is full
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touch(key)
+	c.store(key, value)
+}
+
+// store caches a value evicting the least recently used entry if the cache is full, c.mu must be held
+func (c *lruCache[K, V]) store(key K, value V) {
+	expires := c.config.Clock.Now().Add(c.config.TTL)
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+
+	if c.config.Size > 0 && c.order.Len() > c.config.Size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// delete removes the entry
+func (c *lruCache[K, V]) delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touch(key)
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// remove removes the entries matching the predicate
+func (c *lruCache[K, V]) remove(match func(key K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	if len(c.reads) > 0 {
+		c.removed = append(c.removed, cacheRemoval[K]{match: match, epoch: c.epoch})
+	}
+
+	for key, el := range c.items {
+		if match(key) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// begin starts a backend read and returns its epoch, end must be called when the read is done
+func (c *lruCache[K, V]) begin() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads[c.epoch]++
+
+	return c.epoch
+}
+
+// fill caches a value read from the backend by the read started at the epoch,
+// the value is dropped if the key was written since then
+func (c *lruCache[K, V]) fill(epoch uint64, key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.written[key] > epoch {
+		return
+	}
+	for _, removal := range c.removed {
+		if removal.epoch > epoch && removal.match(key) {
+			return
+		}
+	}
+
+	c.store(key, value)
+}
+
+// end finishes the read started at the epoch and forgets writes no in-flight read can be affected by
+func (c *lruCache[K, V]) end(epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads[epoch]--
+	if c.reads[epoch] == 0 {
+		delete(c.reads, epoch)
+	}
+
+	oldest := c.epoch
+	for e := range c.reads {
+		oldest = min(oldest, e)
+	}
+
+	for key, e := range c.written {
+		if e <= oldest {
+			delete(c.written, key)
+		}
+	}
+	c.removed = slices.DeleteFunc(c.removed, func(removal cacheRemoval[K]) bool {
+		return removal.epoch <= oldest
+	})
+}
+
+// touch records a write of the key, c.mu must be held
+func (c *lruCache[K, V]) touch(key K) {
+	c.epoch++
+	if len(c.reads) > 0 {
+		c.written[key] = c.epoch
+	}
+}
+
+// cachedState is a cached user's state with its version
+type cachedState struct {
+	stateID StateID
+	version uint64
+}
+
+// CachedUserStateStorage is a user state storage decorator caching states in memory.
+// Writes go to the backend and refresh the cache. With several FSM replicas sharing the backend,
+// a replica may read a state changed by another one until the entry expires after TTL.
+// Versioned transitions are still safe: a stale version fails with ErrConcurrentModification
+// and drops the entry
+type CachedUserStateStorage struct {
+	storage UserStateStorage
+	cache   *lruCache[int64, cachedState]
+}
+
+// NewCachedUserStateStorage wraps a user state storage with a cache
+func NewCachedUserStateStorage(storage UserStateStorage, config CacheConfig) *CachedUserStateStorage {
+	return &CachedUserStateStorage{
+		storage: storage,
+		cache:   newLRUCache[int64, cachedState](config),
+	}
+}
+
+// Set sets user's state to the backend and drops the cached one, since its version is unknown
+func (c *CachedUserStateStorage) Set(userID int64, stateID StateID) error {
+	err := c.storage.Set(userID, stateID)
+	c.invalidate(userID)
+
+	return err
+}
+
+// Exists checks whether user's state exists in the cache or the backend
+func (c *CachedUserStateStorage) Exists(userID int64) (bool, error) {
+	if _, ok := c.cache.get(userID); ok {
+		return true, nil
+	}
+
+	return c.storage.Exists(userID)
+}
+
+// Get gets user's state from the cache or the backend
+func (c *CachedUserStateStorage) Get(userID int64) (StateID, error) {
+	stateID, _, err := c.GetVersion(userID)
+
+	return stateID, err
+}
+
+// GetVersion gets user's state with its version from the cache or the backend
+func (c *CachedUserStateStorage) GetVersion(userID int64) (StateID, uint64, error) {
+	if state, ok := c.cache.get(userID); ok {
+		return state.stateID, state.version, nil
+	}
+
+	epoch := c.cache.begin()
+	defer c.cache.end(epoch)
+
+	stateID, version, err := getState(c.storage, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	c.cache.fill(epoch, userID, cachedState{stateID: stateID, version: version})
+
+	return stateID, version, nil
+}
+
+// SetIfVersion sets user's state to the backend if its version matches and refreshes the cache
+func (c *CachedUserStateStorage) SetIfVersion(userID int64, stateID StateID, expectedVersion uint64) (uint64, error) {
+	version, err := setState(c.storage, userID, stateID, expectedVersion)
+	if err != nil {
+		c.invalidate(userID)
+		return 0, err
+	}
+
+	c.cache.put(userID, cachedState{stateID: stateID, version: version})
+
+	return version, nil
+}
+
+// Users returns all users from the backend if it implements UserStateEnumerator
+func (c *CachedUserStateStorage) Users() ([]int64, error) {
+	enumerator, ok := c.storage.(UserStateEnumerator)
+	if !ok {
+		return nil, fmt.Errorf("%w: user state storage can't enumerate users", ErrNotSupported)
+	}
+
+	return enumerator.Users()
+}
+
+// Warm loads states of the users from the backend into the cache, unknown users are skipped
+func (c *CachedUserStateStorage) Warm(ctx context.Context, userIDs []int64) error {
+	for _, userID := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := c.warm(userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// warm loads the user's state from the backend into the cache
+func (c *CachedUserStateStorage) warm(userID int64) error {
+	epoch := c.cache.begin()
+	defer c.cache.end(epoch)
+
+	stateID, version, err := getState(c.storage, userID)
+	if errors.Is(err, ErrNoUserState) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.cache.fill(epoch, userID, cachedState{stateID: stateID, version: version})
+
+	return nil
+}
+
+// Flush flushes the backend if it implements Flusher
+func (c *CachedUserStateStorage) Flush() error {
+	return flush(c.storage)
+}
+
+// Close closes the backend if it implements io.Closer
+func (c *CachedUserStateStorage) Close() error {
+	return closeStorage(c.storage)
+}
+
+// Ping pings the backend if it implements Pinger
+func (c *CachedUserStateStorage) Ping(ctx context.Context) error {
+	return ping(ctx, c.storage)
+}
+
+// invalidate drops the user's cached state
+func (c *CachedUserStateStorage) invalidate(userID int64) {
+	c.cache.delete(userID)
+}
+
+// dataKey is a cache key of user's data
+type dataKey[K comparable] struct {
+	userID int64
+	key    K
+}
+
+// CachedDataStorage is a data storage decorator caching values in memory.
+// Writes go to the backend and refresh the cache. With several FSM replicas sharing the backend,
+// a replica may read a value changed by another one until the entry expires after TTL
+type CachedDataStorage[K comparable, V any] struct {
+	storage DataStorage[K, V]
+	cache   *lruCache[dataKey[K], V]
+}
+
+// NewCachedDataStorage wraps a data storage with a cache
+func NewCachedDataStorage[K comparable, V any](storage DataStorage[K, V], config CacheConfig) *CachedDataStorage[K, V] {
+	return &CachedDataStorage[K, V]{
+		storage: storage,
+		cache:   newLRUCache[dataKey[K], V](config),
+	}
+}
+
+// Set sets user's data to the backend and refreshes the cache
+func (c *CachedDataStorage[K, V]) Set(userID int64, key K, value V) error {
+	err := c.storage.Set(userID, key, value)
+	if err != nil {
+		c.invalidate(userID, key)
+		return err
+	}
+
+	c.cache.put(dataKey[K]{userID: userID, key: key}, value)
+
+	return nil
+}
+
+// Get gets user's data from the cache or the backend. A found value is cached
+// if the backend implements DataLookuper, otherwise it can't tell found values from zero ones
+func (c *CachedDataStorage[K, V]) Get(userID int64, key K) (V, error) {
+	if _, ok := c.storage.(DataLookuper[K, V]); ok {
+		v, found, err := c.Lookup(userID, key)
+		if err != nil || found {
+			return v, err
+		}
+	} else if v, ok := c.cache.get(dataKey[K]{userID: userID, key: key}); ok {
+		return v, nil
+	}
+
+	return c.storage.Get(userID, key)
+}
+
+// Delete deletes user's data from the backend and the cache
+func (c *CachedDataStorage[K, V]) Delete(userID int64, key K) error {
+	err := c.storage.Delete(userID, key)
+	c.invalidate(userID, key)
+
+	return err
+}
+
+// Lookup gets user's data from the cache or the backend if it implements DataLookuper
+func (c *CachedDataStorage[K, V]) Lookup(userID int64, key K) (V, bool, error) {
+	if v, ok := c.cache.get(dataKey[K]{userID: userID, key: key}); ok {
+		return v, true, nil
+	}
+
+	lookuper, ok := c.storage.(DataLookuper[K, V])
+	if !ok {
+		var empty V
+		return empty, false, fmt.Errorf("%w: data storage can't look up keys", ErrNotSupported)
+	}
+
+	epoch := c.cache.begin()
+	defer c.cache.end(epoch)
+
+	v, found, err := lookuper.Lookup(userID, key)
+	if err == nil && found {
+		c.cache.fill(epoch, dataKey[K]{userID: userID, key: key}, v)
+	}
+
+	return v, found, err
+}
+
+// GetAndDelete gets and deletes user's data in the backend if it implements DataGetDeleter, the key is evicted from the cache
+func (c *CachedDataStorage[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	getDeleter, ok := c.storage.(DataGetDeleter[K, V])
+	if !ok {
+		var empty V
+		return empty, false, fmt.Errorf("%w: data storage can't get and delete keys", ErrNotSupported)
+	}
+
+	v, found, err := getDeleter.GetAndDelete(userID, key)
+	c.invalidate(userID, key)
+
+	return v, found, err
+}
+
+// All returns all user's data from the backend if it implements DataEnumerator
+func (c *CachedDataStorage[K, V]) All(userID int64) (map[K]V, error) {
+	enumerator, ok := c.storage.(DataEnumerator[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't enumerate user data", ErrNotSupported)
+	}
+
+	return enumerator.All(userID)
+}
+
+// Count counts user's keys in the backend if it implements DataCounter
+func (c *CachedDataStorage[K, V]) Count(userID int64) (int, error) {
+	counter, ok := c.storage.(DataCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: data storage can't count user data", ErrNotSupported)
+	}
+
+	return counter.Count(userID)
+}
+
+// Append appends a value to user's list in the backend if it implements DataLister, lists aren't cached
+func (c *CachedDataStorage[K, V]) Append(userID int64, key K, value V) error {
+	lister, ok := c.storage.(DataLister[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	return lister.Append(userID, key, value)
+}
+
+// List returns user's list from the backend if it implements DataLister
+func (c *CachedDataStorage[K, V]) List(userID int64, key K) ([]V, error) {
+	lister, ok := c.storage.(DataLister[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	return lister.List(userID, key)
+}
+
+// Lists returns all user's lists from the backend if it implements DataLister
+func (c *CachedDataStorage[K, V]) Lists(userID int64) (map[K][]V, error) {
+	lister, ok := c.storage.(DataLister[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	return lister.Lists(userID)
+}
+
+// SetList replaces user's list in the backend if it implements DataLister
+func (c *CachedDataStorage[K, V]) SetList(userID int64, key K, list []V) error {
+	lister, ok := c.storage.(DataLister[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	return lister.SetList(userID, key, list)
+}
+
+// Clear deletes all user's data from the backend if it implements DataClearer and from the cache
+func (c *CachedDataStorage[K, V]) Clear(userID int64) error {
+	clearer, ok := c.storage.(DataClearer)
+	if !ok {
+		return fmt.Errorf("%w: data storage can't clear user data", ErrNotSupported)
+	}
+
+	err := clearer.Clear(userID)
+	c.cache.remove(func(key dataKey[K]) bool {
+		return key.userID == userID
+	})
+
+	return err
+}
+
+// Warm loads data of the users from the backend into the cache, the backend must implement DataEnumerator
+func (c *CachedDataStorage[K, V]) Warm(ctx context.Context, userIDs []int64) error {
+	enumerator, ok := c.storage.(DataEnumerator[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't enumerate user data", ErrNotSupported)
+	}
+
+	for _, userID := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := c.warm(enumerator, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// warm loads the user's data from the backend into the cache
+func (c *CachedDataStorage[K, V]) warm(enumerator DataEnumerator[K, V], userID int64) error {
+	epoch := c.cache.begin()
+	defer c.cache.end(epoch)
+
+	data, err := enumerator.All(userID)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		c.cache.fill(epoch, dataKey[K]{userID: userID, key: key}, value)
+	}
+
+	return nil
+}
+
+// Flush flushes the backend if it implements Flusher
+func (c *CachedDataStorage[K, V]) Flush() error {
+	return flush(c.storage)
+}
+
+// Close closes the backend if it implements io.Closer
+func (c *CachedDataStorage[K, V]) Close() error {
+	return closeStorage(c.storage)
+}
+
+// Ping pings the backend if it implements Pinger
+func (c *CachedDataStorage[K, V]) Ping(ctx context.Context) error {
+	return ping(ctx, c.storage)
+}
+
+// invalidate drops the user's cached value
+func (c *CachedDataStorage[K, V]) invalidate(userID int64, key K) {
+	c.cache.delete(dataKey[K]{userID: userID, key: key})
+}
@@ -0,0 +1,228 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingHook counts done storage operations by name
+type countingHook map[string]int
+
+func (c countingHook) hook(event StorageEvent) {
+	if event.Done {
+		c[event.Op]++
+	}
+}
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// slowStates is a user state storage running during after reading a state, like a write racing a slow read
+type slowStates struct {
+	*userStateStorage
+	during func()
+}
+
+func (s slowStates) GetVersion(userID int64) (StateID, uint64, error) {
+	stateID, version, err := s.userStateStorage.GetVersion(userID)
+	if s.during != nil {
+		s.during()
+	}
+	return stateID, version, err
+}
+
+// slowData is a data storage running during after looking up a value
+type slowData struct {
+	*dataStorage[string, int]
+	during func()
+}
+
+func (s slowData) Lookup(userID int64, key string) (int, bool, error) {
+	v, ok, err := s.dataStorage.Lookup(userID, key)
+	if s.during != nil {
+		s.during()
+	}
+	return v, ok, err
+}
+
+func TestCachedUserStateStorage(t *testing.T) {
+	calls := countingHook{}
+	backend := NewObservableUserStateStorage(initialUserStateStorage(), calls.hook)
+	cache := NewCachedUserStateStorage(backend, CacheConfig{})
+
+	if err := cache.Set(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if stateID, err := cache.Get(1); err != nil || stateID != "a" {
+			t.Fatalf("expected state a, got %s, %v", stateID, err)
+		}
+	}
+	if calls["GetVersion"] != 1 {
+		t.Fatalf("expected 1 backend read, got %d", calls["GetVersion"])
+	}
+
+	if err := cache.Set(1, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if stateID, _ := cache.Get(1); stateID != "b" {
+		t.Fatalf("expected invalidated state b, got %s", stateID)
+	}
+	if calls["GetVersion"] != 2 {
+		t.Fatalf("expected a backend read after the write, got %d", calls["GetVersion"])
+	}
+}
+
+func TestCachedUserStateStorageTTL(t *testing.T) {
+	calls := countingHook{}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	backend := NewObservableUserStateStorage(initialUserStateStorage(), calls.hook)
+	cache := NewCachedUserStateStorage(backend, CacheConfig{TTL: time.Minute, Clock: clock})
+
+	if err := cache.Set(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = cache.Get(1)
+	_, _ = cache.Get(1)
+	clock.now = clock.now.Add(time.Minute)
+	_, _ = cache.Get(1)
+
+	if calls["GetVersion"] != 2 {
+		t.Fatalf("expected the expired entry to be read again, got %d reads", calls["GetVersion"])
+	}
+}
+
+func TestCachedUserStateStorageSkipsStaleFill(t *testing.T) {
+	backend := slowStates{userStateStorage: initialUserStateStorage()}
+	cache := NewCachedUserStateStorage(&backend, CacheConfig{})
+	if err := cache.Set(1, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.during = func() {
+		backend.during = nil
+		if err := cache.Set(1, "new"); err != nil {
+			t.Error(err)
+		}
+	}
+	if stateID, _ := cache.Get(1); stateID != "old" {
+		t.Fatalf("expected the read to return old, got %s", stateID)
+	}
+
+	if stateID, _ := cache.Get(1); stateID != "new" {
+		t.Fatalf("expected new after the racing write, got %s", stateID)
+	}
+}
+
+func TestCachedDataStorage(t *testing.T) {
+	calls := countingHook{}
+	backend := NewObservableDataStorage[string, int](initialDataStorage[string, int](), calls.hook)
+	cache := NewCachedDataStorage[string, int](backend, CacheConfig{Size: 1})
+	f := New[string, int]("a", nil, WithDataStorage[string, int](cache))
+
+	if err := backend.Set(1, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := f.Get(1, "k"); err != nil || v != 1 {
+			t.Fatalf("expected 1, got %d, %v", v, err)
+		}
+	}
+	if calls["Lookup"] != 1 || calls["Get"] != 0 {
+		t.Fatalf("expected a single backend read, got %v", calls)
+	}
+
+	if err := f.Set(1, "k", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.Get(1, "k"); v != 2 {
+		t.Fatalf("expected refreshed 2, got %d", v)
+	}
+
+	// the cache holds a single entry, so reading another key evicts k
+	if err := backend.Set(1, "other", 3); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Get(1, "other")
+	reads := calls["Lookup"]
+	_, _ = f.Get(1, "k")
+	if calls["Lookup"] != reads+1 {
+		t.Fatalf("expected evicted key to be read from the backend")
+	}
+
+	if err := f.Delete(1, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := cache.Lookup(1, "k"); ok {
+		t.Fatal("expected deleted key to be invalidated")
+	}
+}
+
+func TestCachedDataStorageSkipsStaleFill(t *testing.T) {
+	backend := slowData{dataStorage: initialDataStorage[string, int]()}
+	cache := NewCachedDataStorage[string, int](&backend, CacheConfig{})
+	if err := cache.Set(1, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	cache.invalidate(1, "k")
+
+	backend.during = func() {
+		backend.during = nil
+		if err := cache.Delete(1, "k"); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, ok, _ := cache.Lookup(1, "k"); !ok {
+		t.Fatal("expected the read to find the old value")
+	}
+
+	if _, ok, _ := cache.Lookup(1, "k"); ok {
+		t.Fatal("expected the racing delete to win over the stale fill")
+	}
+}
+
+func TestWarm(t *testing.T) {
+	stateCalls, dataCalls := countingHook{}, countingHook{}
+	states := NewObservableUserStateStorage(initialUserStateStorage(), stateCalls.hook)
+	data := NewObservableDataStorage[string, int](initialDataStorage[string, int](), dataCalls.hook)
+	for _, userID := range []int64{1, 2} {
+		if err := states.Set(userID, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := data.Set(userID, "k", int(userID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := New[string, int]("a", nil,
+		WithUserStateStorage[string, int](NewCachedUserStateStorage(states, CacheConfig{})),
+		WithDataStorage[string, int](NewCachedDataStorage[string, int](data, CacheConfig{})),
+	)
+	if err := f.Warm(context.Background(), []int64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	reads := func() int {
+		return stateCalls["Get"] + stateCalls["GetVersion"] + stateCalls["Exists"] +
+			dataCalls["Get"] + dataCalls["Lookup"] + dataCalls["All"]
+	}
+	warmed := reads()
+
+	for _, userID := range []int64{1, 2} {
+		if stateID, err := f.Current(userID); err != nil || stateID != "b" {
+			t.Fatalf("expected state b, got %s, %v", stateID, err)
+		}
+		if v, err := f.Get(userID, "k"); err != nil || v != int(userID) {
+			t.Fatalf("expected %d, got %d, %v", userID, v, err)
+		}
+	}
+
+	if n := reads() - warmed; n != 0 {
+		t.Fatalf("expected warmed reads not to hit the backends, got %d reads: %v, %v", n, stateCalls, dataCalls)
+	}
+}
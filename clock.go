@@ -0,0 +1,16 @@
+package fsm
+
+import "time"
+
+// Clock is an interface for getting the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a default clock that returns the system time
+type systemClock struct{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
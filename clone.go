@@ -0,0 +1,89 @@
+package fsm
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+)
+
+// CloneConfig returns a new FSM with the same initial state, callbacks, transitions, hooks and options.
+// User states and data are NOT copied, the clone has fresh in-memory storages, including region storages,
+// and its own rate limiter buckets, sequence numbers and idempotency keys
+func (f *FSM[K, V]) CloneConfig() *FSM[K, V] {
+	f.mu.Lock()
+	seed := f.rand.Int63()
+	f.mu.Unlock()
+
+	f.cbMu.RLock()
+	defer f.cbMu.RUnlock()
+
+	c := &FSM[K, V]{
+		initialStateID: f.initialStateID,
+		callbacks:      maps.Clone(f.callbacks),
+		userStates:     initialUserStateStorage(),
+		storage:        initialDataStorage[K, V](),
+		locales:        initialLocaleStorage(),
+
+		idempotencyWindow: f.idempotencyWindow,
+		callbackContext:   f.callbackContext,
+		clock:             f.clock,
+		rateLimits:        make(map[StateID]*rateLimiter, len(f.rateLimits)),
+		stateMeta:         maps.Clone(f.stateMeta),
+		explicitInit:      f.explicitInit,
+		explicitStart:     f.explicitStart,
+		initialStateFunc:  f.initialStateFunc,
+		recoveryState:     f.recoveryState,
+		noInitialCallback: f.noInitialCallback,
+		maxDepth:          f.maxDepth,
+		proposalTTL:       f.proposalTTL,
+		runInitialOnSeed:  f.runInitialOnSeed,
+		defaultLocale:     f.defaultLocale,
+		authorizers:       maps.Clone(f.authorizers),
+		regions:           make(map[string]UserStateStorage, len(f.regions)),
+		observers:         make(map[StateID][]TransitionObserverCallback, len(f.observers)),
+		globalObservers:   slices.Clone(f.globalObservers),
+		validators:        maps.Clone(f.validators),
+		randomTransitions: maps.Clone(f.randomTransitions),
+		variantKeys:       f.variantKeys,
+		rand:              rand.New(rand.NewSource(seed)),
+		routes:            maps.Clone(f.routes),
+		beforeHooks:       slices.Clone(f.beforeHooks),
+		afterHooks:        slices.Clone(f.afterHooks),
+		argTypes:          maps.Clone(f.argTypes),
+		sticky:            maps.Clone(f.sticky),
+		maxKeys:           f.maxKeys,
+		deferred:          maps.Clone(f.deferred),
+		errorHandler:      f.errorHandler,
+		patterns:          slices.Clone(f.patterns),
+		encode:            f.encode,
+		decode:            f.decode,
+		processed:         newProcessedKeys(),
+		seqs:              make(map[int64]uint64),
+		visits:            make(map[int64]map[StateID]int),
+		proposals:         make(map[int64]proposal),
+	}
+
+	for stateID, limiter := range f.rateLimits {
+		c.rateLimits[stateID] = newRateLimiter(limiter.limit, limiter.burst)
+	}
+
+	for region := range f.regions {
+		c.regions[region] = initialUserStateStorage()
+	}
+
+	for stateID, observers := range f.observers {
+		c.observers[stateID] = slices.Clone(observers)
+	}
+
+	if f.callbackSlots != nil {
+		c.callbackSlots = make(chan struct{}, cap(f.callbackSlots))
+	}
+
+	if f.queues != nil {
+		c.queues = newUserQueues(f.queues.size)
+	}
+
+	c.wrapStorage()
+
+	return c
+}
@@ -0,0 +1,55 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCloneConfig(t *testing.T) {
+	ctx := context.Background()
+
+	var calls int
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			calls++
+			return nil
+		},
+	}, WithStickyStates[string, int]("a"))
+	f.AddInputRoute("a", "next", "b")
+	f.SetStateMeta("b", map[string]any{"title": "B"})
+	if err := f.Set(1, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	c := f.CloneConfig()
+
+	for _, stateID := range []StateID{"a", "b"} {
+		if got, want := c.StateInfo(stateID), f.StateInfo(stateID); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected state %s info %+v, got %+v", stateID, want, got)
+		}
+	}
+
+	if ok, err := c.userStates.Exists(1); err != nil || ok {
+		t.Fatalf("expected the clone to have no users, got %t, %v", ok, err)
+	}
+	if _, err := c.Get(1, "k"); err == nil {
+		t.Fatal("expected the clone to have no data")
+	}
+
+	if _, err := c.RouteInput(ctx, 1, "next"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustState(t, c, 1); got != "b" {
+		t.Fatalf("expected the clone to route to b, got %s", got)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the cloned callback to be called, got %d calls", calls)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected the original user to stay in a, got %s", got)
+	}
+}
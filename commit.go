@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CommitAndTransition writes the data and transitions the user to a new state under the user's lock
+// before the callback is called, so the data and the state move together. If writing either of them
+// or the callback fails, both the data and the state are rolled back. Data storage must implement DataLookuper
+func (f *FSM[K, V]) CommitAndTransition(ctx context.Context, userID int64, stateID StateID, data map[K]V, args ...any) error {
+	err := f.transition(ctx, transitionRequest{
+		states: f.userStates,
+		userID: userID,
+		next:   toState(stateID),
+		commit: func() (func() error, error) {
+			return f.writeData(userID, data)
+		},
+		args: args,
+	})
+
+	return wrapError("commit and transition", userID, stateID, err)
+}
+
+// previousValue is a value of a key before it was overwritten
+type previousValue[V any] struct {
+	value V
+	ok    bool
+}
+
+// writeData writes the data and returns a function restoring the previous values,
+// a failed write is rolled back at once. The user's lock must be held
+func (f *FSM[K, V]) writeData(userID int64, data map[K]V) (func() error, error) {
+	previous := make(map[K]previousValue[V], len(data))
+
+	undo := func() error {
+		var errs []error
+		for key, prev := range previous {
+			var err error
+			if prev.ok {
+				err = f.storage.Set(userID, key, prev.value)
+			} else {
+				err = f.storage.Delete(userID, key)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore user data: %w", err))
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	for key, value := range data {
+		v, ok, err := f.lookup(userID, key)
+		if err != nil {
+			return nil, errors.Join(err, undo())
+		}
+		previous[key] = previousValue[V]{value: v, ok: ok}
+
+		err = f.set(userID, key, value)
+		if err != nil {
+			return nil, errors.Join(err, undo())
+		}
+	}
+
+	return undo, nil
+}
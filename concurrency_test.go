@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbackConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			return nil
+		},
+	}, WithCallbackConcurrency[string, int](2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		if err := f.Init(int64(i)); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			if err := f.Transition(ctx, userID, "b"); err != nil {
+				t.Error(err)
+			}
+		}(int64(i))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for running.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if p := peak.Load(); p != 2 {
+		t.Fatalf("expected at most 2 concurrent callbacks, peak was %d", p)
+	}
+}
+
+func TestCallbackConcurrencyChainedReusesSlot(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("a", map[StateID]Callback{
+		"b": func(ctx context.Context, _ ...any) error { return f.Transition(ctx, 1, "c") },
+		"c": noop,
+	}, WithCallbackConcurrency[string, int](1))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- f.Transition(ctx, 1, "b") }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("chained transition waited for its own slot")
+	}
+}
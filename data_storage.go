@@ -2,6 +2,7 @@ package fsm
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 )
 
@@ -9,12 +10,14 @@ import (
 type dataStorage[K comparable, V any] struct {
 	mu      sync.Mutex
 	Storage map[int64]map[K]V
+	lists   map[int64]map[K][]V
 }
 
 // initialDataStorage creates in memory storage for user's data
 func initialDataStorage[K comparable, V any]() *dataStorage[K, V] {
 	return &dataStorage[K, V]{
 		Storage: make(map[int64]map[K]V),
+		lists:   make(map[int64]map[K][]V),
 	}
 }
 
@@ -60,3 +63,116 @@ func (d *dataStorage[K, V]) Delete(userID int64, key K) error {
 
 	return nil
 }
+
+// All returns a copy of all user's data from data storage
+func (d *dataStorage[K, V]) All(userID int64) (map[K]V, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data := make(map[K]V, len(d.Storage[userID]))
+	for key, value := range d.Storage[userID] {
+		data[key] = value
+	}
+
+	return data, nil
+}
+
+// Lookup gets user's data from data storage, it returns false if there is no such key
+func (d *dataStorage[K, V]) Lookup(userID int64, key K) (V, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, ok := d.Storage[userID][key]
+
+	return v, ok, nil
+}
+
+// GetAndDelete gets user's data and deletes it from data storage, it returns false if there is no such key
+func (d *dataStorage[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, ok := d.Storage[userID][key]
+	if ok {
+		delete(d.Storage[userID], key)
+	}
+
+	return v, ok, nil
+}
+
+// Count returns the number of user's keys in data storage
+func (d *dataStorage[K, V]) Count(userID int64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.Storage[userID]), nil
+}
+
+// Append appends a value to user's list in data storage
+func (d *dataStorage[K, V]) Append(userID int64, key K, value V) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	l, ok := d.lists[userID]
+	if !ok {
+		l = make(map[K][]V)
+		d.lists[userID] = l
+	}
+
+	l[key] = append(l[key], value)
+
+	return nil
+}
+
+// List returns a copy of user's list from data storage
+func (d *dataStorage[K, V]) List(userID int64, key K) ([]V, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.lists[userID][key]), nil
+}
+
+// Lists returns a copy of all user's lists from data storage
+func (d *dataStorage[K, V]) Lists(userID int64) (map[K][]V, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lists := make(map[K][]V, len(d.lists[userID]))
+	for key, list := range d.lists[userID] {
+		lists[key] = slices.Clone(list)
+	}
+
+	return lists, nil
+}
+
+// SetList replaces user's list in data storage, an empty list deletes it
+func (d *dataStorage[K, V]) SetList(userID int64, key K, list []V) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(list) == 0 {
+		delete(d.lists[userID], key)
+		return nil
+	}
+
+	l, ok := d.lists[userID]
+	if !ok {
+		l = make(map[K][]V)
+		d.lists[userID] = l
+	}
+
+	l[key] = slices.Clone(list)
+
+	return nil
+}
+
+// Clear deletes all user's data from data storage
+func (d *dataStorage[K, V]) Clear(userID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.Storage, userID)
+	delete(d.lists, userID)
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package fsm
+
+import "context"
+
+// ErrorHandler is a function that will be called with an error of a deferred callback
+type ErrorHandler func(ctx context.Context, userID int64, stateID StateID, err error)
+
+// AddDeferredCallback adds a callback for a state that is called in a new goroutine after the state is set,
+// Transition doesn't wait for it. The error of a deferred callback doesn't roll back the transition,
+// it's passed to the handler set by WithErrorHandler. Close waits for the running deferred callbacks
+func (f *FSM[K, V]) AddDeferredCallback(stateID StateID, callback Callback) {
+	f.cbMu.Lock()
+	defer f.cbMu.Unlock()
+
+	f.addCallback(stateID, callback)
+	if callback != nil {
+		f.deferred[stateID] = true
+	}
+}
+
+// isDeferred reports whether the state's callback was added by AddDeferredCallback
+func (f *FSM[K, V]) isDeferred(stateID StateID) bool {
+	f.cbMu.RLock()
+	defer f.cbMu.RUnlock()
+
+	return f.deferred[stateID]
+}
+
+// runDeferred calls the callback in a new goroutine. Its context isn't canceled with ctx
+// and isn't bound to the caller's user queue and callback slot
+func (f *FSM[K, V]) runDeferred(ctx context.Context, userID int64, stateID StateID, cb Callback, args ...any) {
+	ctx = context.WithoutCancel(ctx)
+	ctx = context.WithValue(ctx, queueKey{}, nil)
+	ctx = context.WithValue(ctx, callbackSlotKey{}, nil)
+
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+
+		err := f.runCallback(ctx, cb, args...)
+		if err != nil && f.errorHandler != nil {
+			f.errorHandler(ctx, userID, stateID, err)
+		}
+	}()
+}
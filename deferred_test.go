@@ -0,0 +1,84 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeferredCallback(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	handled := make(chan error, 1)
+	f := New[string, int]("a", nil, WithErrorHandler[string, int](func(_ context.Context, userID int64, stateID StateID, err error) {
+		if userID == 1 && stateID == "b" {
+			handled <- err
+		}
+	}))
+	f.AddDeferredCallback("b", func(context.Context, ...any) error {
+		defer close(done)
+		<-release
+		return errTest
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Transition(ctx, 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustState(t, f, 1); got != "b" {
+		t.Fatalf("expected the state to be committed before the callback, got %s", got)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the callback to run after Transition returns")
+	default:
+	}
+
+	close(release)
+	select {
+	case err := <-handled:
+		if !errors.Is(err, errTest) {
+			t.Fatalf("expected the callback error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the error to reach the handler")
+	}
+	if got := mustState(t, f, 1); got != "b" {
+		t.Fatalf("expected the deferred error not to roll back, got %s", got)
+	}
+}
+
+func TestCloseWaitsForDeferredCallbacks(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var finished bool
+	f := New[string, int]("a", nil)
+	f.AddDeferredCallback("b", func(context.Context, ...any) error {
+		<-release
+		finished = true
+		return nil
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Transition(ctx, 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	if err := f.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !finished {
+		t.Fatal("expected Close to wait for the deferred callback")
+	}
+}
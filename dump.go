@@ -0,0 +1,168 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// dumpRecord is a single user record of a dump
+type dumpRecord[K comparable, V any] struct {
+	UserID int64             `json:"user_id"`
+	State  StateID           `json:"state"`
+	Data   []dumpEntry[K, V] `json:"data,omitempty"`
+	Lists  []dumpList[K, V]  `json:"lists,omitempty"`
+	Locale string            `json:"locale,omitempty"`
+	// Regions are the user's states in orthogonal regions
+	Regions map[string]StateID `json:"regions,omitempty"`
+}
+
+// dumpEntry is a single data key-value pair of a dump record.
+// Data is dumped as a list of pairs, because JSON objects only support string keys
+type dumpEntry[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+// dumpList is a single list of a dump record
+type dumpList[K comparable, V any] struct {
+	Key    K   `json:"key"`
+	Values []V `json:"values"`
+}
+
+// Dump streams all users with their state, region states, data, lists and locale to w as newline-delimited JSON, one user per line.
+// Storages must implement UserStateEnumerator and DataEnumerator
+func (f *FSM[K, V]) Dump(ctx context.Context, w io.Writer) error {
+	userIDs, err := f.users()
+	if err != nil {
+		return wrapError("dump", 0, "", err)
+	}
+
+	enc := json.NewEncoder(w)
+
+	for _, userID := range userIDs {
+		if err = ctx.Err(); err != nil {
+			return wrapError("dump", 0, "", err)
+		}
+
+		err = f.dumpUser(enc, userID)
+		if err != nil {
+			return wrapError("dump", userID, "", err)
+		}
+	}
+
+	return nil
+}
+
+// dumpUser encodes a record of the user
+func (f *FSM[K, V]) dumpUser(enc *json.Encoder, userID int64) error {
+	record := dumpRecord[K, V]{UserID: userID}
+
+	var err error
+	record.State, err = f.userStates.Get(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	data, err := f.all(userID)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		record.Data = append(record.Data, dumpEntry[K, V]{Key: key, Value: value})
+	}
+
+	lists, err := f.lists(userID)
+	if err != nil {
+		return err
+	}
+
+	for key, values := range lists {
+		record.Lists = append(record.Lists, dumpList[K, V]{Key: key, Values: values})
+	}
+
+	record.Locale, _, err = f.locale(userID)
+	if err != nil {
+		return err
+	}
+
+	record.Regions, err = f.regionStates(userID)
+	if err != nil {
+		return err
+	}
+
+	err = enc.Encode(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode user: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads users written by Dump from r and stores their state, region states, data, lists and locale
+// without firing callbacks.
+// Loaded lists replace existing ones, data storage must implement DataLister if the dump has lists
+func (f *FSM[K, V]) Load(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return wrapError("load", 0, "", err)
+		}
+
+		var record dumpRecord[K, V]
+
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return wrapError("load", 0, "", fmt.Errorf("failed to decode user: %w", err))
+		}
+
+		err = f.loadUser(record)
+		if err != nil {
+			return wrapError("load", record.UserID, record.State, err)
+		}
+	}
+}
+
+// loadUser stores a record of the user
+func (f *FSM[K, V]) loadUser(record dumpRecord[K, V]) error {
+	for _, entry := range record.Data {
+		err := f.storage.Set(record.UserID, entry.Key, entry.Value)
+		if err != nil {
+			return fmt.Errorf("failed to set user data: %w", err)
+		}
+	}
+
+	lists := make(map[K][]V, len(record.Lists))
+	for _, list := range record.Lists {
+		lists[list.Key] = list.Values
+	}
+
+	err := f.setLists(record.UserID, lists)
+	if err != nil {
+		return err
+	}
+
+	err = f.setLocale(record.UserID, record.Locale)
+	if err != nil {
+		return err
+	}
+
+	err = f.setRegionStates(record.UserID, record.Regions)
+	if err != nil {
+		return err
+	}
+
+	err = f.userStates.Set(record.UserID, record.State)
+	if err != nil {
+		return fmt.Errorf("failed to set user state: %w", err)
+	}
+
+	return nil
+}
@@ -1,8 +1,73 @@
 package fsm
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrNoUserData  = errors.New("no user data")
-	ErrNoUserState = errors.New("no user state")
+	ErrNoUserData             = errors.New("no user data")
+	ErrNoUserState            = errors.New("no user state")
+	ErrInvalidInput           = errors.New("invalid input")
+	ErrClosed                 = errors.New("fsm is closed")
+	ErrNotSupported           = errors.New("not supported by storage")
+	ErrDuplicateTransition    = errors.New("duplicate transition")
+	ErrRateLimited            = errors.New("callback rate limited")
+	ErrConcurrentModification = errors.New("concurrent modification")
+	ErrNoRandomTransition     = errors.New("no random transition")
+	ErrArgMismatch            = errors.New("callback args mismatch")
+	ErrVetoed                 = errors.New("transition aborted by before hook")
+	ErrTooManyKeys            = errors.New("too many user data keys")
+	ErrCallbackPanic          = errors.New("callback panicked")
+	ErrNoInitialCallback      = errors.New("initial state has no callback")
+	ErrTransitionLoop         = errors.New("transition loop")
+	ErrInvalidProposal        = errors.New("invalid transition proposal")
+	ErrProposalExpired        = errors.New("transition proposal expired")
+	ErrUnauthorized           = errors.New("user isn't authorized to enter state")
+	ErrStay                   = errors.New("stay in state")
 )
+
+// Error is an error of FSM operation with the user and state context
+type Error struct {
+	// Op is a name of the failed operation, e.g. "transition"
+	Op string
+	// UserID is the user of the failed operation, 0 for failures of operations over all users
+	UserID int64
+	// StateID is the target state of the failed operation, empty if not applicable
+	StateID StateID
+	// Err is the underlying error
+	Err error
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	if e.StateID != "" {
+		return fmt.Sprintf("%s: userID: %d, state: %s: %v", e.Op, e.UserID, e.StateID, e.Err)
+	}
+
+	return fmt.Sprintf("%s: userID: %d: %v", e.Op, e.UserID, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps a non-nil error with the operation context, an *Error itself is returned as is,
+// while an *Error nested deeper, e.g. of another user's call made by a callback, is wrapped
+func wrapError(op string, userID int64, stateID StateID, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	return &Error{
+		Op:      op,
+		UserID:  userID,
+		StateID: stateID,
+		Err:     err,
+	}
+}
@@ -0,0 +1,198 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWrapErrorKeepsOuterContext(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			_, err := f.Get(42, "k")
+			return err
+		},
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(ctx, 1, "b")
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if e.Op != "transition" || e.UserID != 1 || e.StateID != "b" {
+		t.Fatalf("expected outer transition context, got %s, %d, %s", e.Op, e.UserID, e.StateID)
+	}
+	if !errors.Is(err, ErrNoUserData) {
+		t.Fatalf("expected ErrNoUserData, got %v", err)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	inner := &Error{Op: "get", UserID: 42, Err: errTest}
+
+	tests := []struct {
+		name   string
+		err    error
+		wantOp string
+	}{
+		{name: "nil", err: nil},
+		{name: "plain", err: errTest, wantOp: "transition"},
+		{name: "error itself", err: inner, wantOp: "get"},
+		{name: "nested error", err: errors.Join(errTest, inner), wantOp: "transition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapError("transition", 1, "b", tt.err)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("expected nil, got %v", err)
+				}
+				return
+			}
+
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("expected *Error, got %T", err)
+			}
+			if e.Op != tt.wantOp {
+				t.Fatalf("expected op %s, got %s", tt.wantOp, e.Op)
+			}
+		})
+	}
+}
+
+// failingStates is a user state storage failing to read and warm states
+type failingStates struct {
+	*userStateStorage
+}
+
+func (s failingStates) Get(int64) (StateID, error) { return "", errTest }
+
+func (s failingStates) Exists(int64) (bool, error) { return false, errTest }
+
+func (s failingStates) Warm(context.Context, []int64) error { return errTest }
+
+func TestMethodsReturnError(t *testing.T) {
+	ctx := context.Background()
+
+	failing := func() *FSM[string, any] {
+		states := failingStates{initialUserStateStorage()}
+		if err := states.Set(1, "a"); err != nil {
+			t.Fatal(err)
+		}
+		return New[string, any]("a", nil, WithUserStateStorage[string, any](states))
+	}
+	unenumerable := func() *FSM[string, any] {
+		return New[string, any]("a", nil, WithUserStateStorage[string, any](unversioned{initialUserStateStorage()}))
+	}
+
+	tests := []struct {
+		name   string
+		op     string
+		userID int64
+		call   func() error
+	}{
+		{name: "inspect", op: "inspect", userID: 1, call: func() error {
+			_, err := failing().Inspect(1)
+			return err
+		}},
+		{name: "dump", op: "dump", userID: 1, call: func() error {
+			return failing().Dump(ctx, io.Discard)
+		}},
+		{name: "load", op: "load", call: func() error {
+			return unenumerable().Load(ctx, strings.NewReader("{"))
+		}},
+		{name: "migrate", op: "migrate", userID: 1, call: func() error {
+			return failing().Migrate(ctx, New[string, any]("a", nil), nil)
+		}},
+		{name: "range", op: "range", userID: 1, call: func() error {
+			return failing().Range(func(int64, StateID) bool { return true })
+		}},
+		{name: "collect data", op: "collect data", userID: 1, call: func() error {
+			_, err := failing().CollectData("a", "k")
+			return err
+		}},
+		{name: "reset all enumeration", op: "reset all", call: func() error {
+			_, err := unenumerable().ResetAll(ctx, nil)
+			return err
+		}},
+		{name: "rename state enumeration", op: "rename state", call: func() error {
+			_, err := unenumerable().RenameState(ctx, "a", "b")
+			return err
+		}},
+		{name: "close", op: "close", call: func() error {
+			entered, release := make(chan struct{}), make(chan struct{})
+			f := New[string, any]("a", map[StateID]Callback{"b": func(context.Context, ...any) error {
+				close(entered)
+				<-release
+				return nil
+			}})
+			if err := f.Init(1); err != nil {
+				t.Fatal(err)
+			}
+			go func() { _ = f.Transition(ctx, 1, "b") }()
+			<-entered
+			defer close(release)
+
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			return f.Close(canceled)
+		}},
+		{name: "wait ready", op: "wait ready", call: func() error {
+			return unenumerable().WaitReady(ctx, 0)
+		}},
+		{name: "warm", op: "warm", call: func() error {
+			return failing().Warm(ctx, []int64{1})
+		}},
+		{name: "session", op: "get session", userID: 1, call: func() error {
+			f := New[string, any]("a", nil)
+			if err := f.Set(1, "session", "not an int"); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := NewSession[int](f, "session").Get(1)
+			return err
+		}},
+		{name: "session update", op: "update session", userID: 1, call: func() error {
+			f := New[string, any]("a", nil)
+			if err := f.Set(1, "session", "not an int"); err != nil {
+				t.Fatal(err)
+			}
+			return NewSession[int](f, "session").Update(1, func(n int) int { return n + 1 })
+		}},
+		{name: "form", op: "handle form", userID: 1, call: func() error {
+			f := New[string, any]("a", nil)
+			fm := NewForm(f, []Step[string, any]{{
+				State:    "age",
+				Key:      "age",
+				Validate: func(string) (any, error) { return nil, errTest },
+			}}, func(context.Context, int64, string) error { return nil }, nil)
+			if err := fm.Start(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+			_, err := fm.Handle(ctx, 1, "old")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected *Error, got %v", err)
+			}
+			if e.Op != tt.op || e.UserID != tt.userID {
+				t.Fatalf("expected %s of user %d, got %s of user %d", tt.op, tt.userID, e.Op, e.UserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// PromptFunc is a function that sends a prompt text to the user
+type PromptFunc func(ctx context.Context, userID int64, text string) error
+
+// CompleteFunc is a function that will be called when the user has passed all form steps
+type CompleteFunc func(ctx context.Context, userID int64) error
+
+// Step is a single step of a Form
+type Step[K comparable, V any] struct {
+	// State is a state of the user while the step is active
+	State StateID
+	// Prompt is a text that will be sent to the user on entering the step
+	Prompt string
+	// Validate validates the user's input and converts it to a value for data storage,
+	// if it's nil the input is stored as is, so V must hold strings
+	Validate func(input string) (V, error)
+	// Key is a data storage key for the step value
+	Key K
+}
+
+// CancelInput is an input that cancels a form in Handle
+const CancelInput = "/cancel"
+
+// Form is a declarative multi-step form built on top of FSM
+type Form[K comparable, V any] struct {
+	fsm        *FSM[K, V]
+	steps      []Step[K, V]
+	index      map[StateID]int
+	prompt     PromptFunc
+	onComplete CompleteFunc
+}
+
+// NewForm creates a new Form and registers callbacks for its steps in FSM
+func NewForm[K comparable, V any](f *FSM[K, V], steps []Step[K, V], prompt PromptFunc, onComplete CompleteFunc) *Form[K, V] {
+	form := &Form[K, V]{
+		fsm:        f,
+		steps:      steps,
+		index:      make(map[StateID]int, len(steps)),
+		prompt:     prompt,
+		onComplete: onComplete,
+	}
+
+	for i, step := range steps {
+		form.index[step.State] = i
+		f.AddCallback(step.State, form.callbackPrompt(step.Prompt))
+	}
+
+	return form
+}
+
+// callbackPrompt returns a callback that sends the step prompt to the user, so any transition to the step prompts
+func (fm *Form[K, V]) callbackPrompt(text string) Callback {
+	return func(ctx context.Context, _ ...any) error {
+		userID, ok := UserIDFromContext(ctx)
+		if !ok {
+			return fmt.Errorf("%w: no user in callback context", ErrInvalidInput)
+		}
+
+		return fm.prompt(ctx, userID, text)
+	}
+}
+
+// Start starts the form for the user from the first step
+func (fm *Form[K, V]) Start(ctx context.Context, userID int64) error {
+	if len(fm.steps) == 0 {
+		return nil
+	}
+
+	err := fm.fsm.Init(userID)
+	if err != nil {
+		return err
+	}
+
+	return fm.fsm.Transition(ctx, userID, fm.steps[0].State)
+}
+
+// Handle handles the user's input for the current form step, CancelInput cancels the form.
+// It returns false if the user is not on any step of the form.
+// Validation errors are wrapped with ErrInvalidInput, the user stays on the same step
+func (fm *Form[K, V]) Handle(ctx context.Context, userID int64, input string) (bool, error) {
+	current, err := fm.fsm.Current(userID)
+	if err != nil {
+		return false, err
+	}
+
+	i, ok := fm.index[current]
+	if !ok {
+		return false, nil
+	}
+
+	if input == CancelInput {
+		return true, fm.Cancel(userID)
+	}
+
+	step := fm.steps[i]
+
+	value, err := fm.validate(step, input)
+	if err != nil {
+		return true, wrapError("handle form", userID, step.State, fmt.Errorf("%w: %w", ErrInvalidInput, err))
+	}
+
+	err = fm.fsm.Set(userID, step.Key, value)
+	if err != nil {
+		return true, err
+	}
+
+	if i+1 < len(fm.steps) {
+		return true, fm.fsm.Transition(ctx, userID, fm.steps[i+1].State)
+	}
+
+	if fm.onComplete != nil {
+		err = fm.onComplete(ctx, userID)
+		if err != nil {
+			return true, wrapError("handle form", userID, step.State, fmt.Errorf("failed to complete form: %w", err))
+		}
+	}
+
+	return true, fm.fsm.Reset(userID)
+}
+
+// Cancel cancels the form and resets the user to the initial state
+func (fm *Form[K, V]) Cancel(userID int64) error {
+	return fm.fsm.Reset(userID)
+}
+
+// validate converts the input to a step value, the input is taken as is if the step has no validator
+func (fm *Form[K, V]) validate(step Step[K, V], input string) (V, error) {
+	if step.Validate == nil {
+		return fromString[V](input)
+	}
+
+	return step.Validate(input)
+}
@@ -0,0 +1,116 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// formFixture is a two-step form with recorded prompts and completions
+type formFixture struct {
+	fsm       *FSM[string, any]
+	form      *Form[string, any]
+	prompts   []string
+	completed []int64
+}
+
+func newFormFixture() *formFixture {
+	fx := &formFixture{fsm: New[string, any]("idle", nil)}
+	fx.form = NewForm(fx.fsm, []Step[string, any]{
+		{State: "name", Prompt: "name?", Key: "name"},
+		{State: "age", Prompt: "age?", Key: "age", Validate: func(input string) (any, error) {
+			return strconv.Atoi(input)
+		}},
+	}, func(_ context.Context, _ int64, text string) error {
+		fx.prompts = append(fx.prompts, text)
+		return nil
+	}, func(_ context.Context, userID int64) error {
+		fx.completed = append(fx.completed, userID)
+		return nil
+	})
+
+	return fx
+}
+
+func TestFormCompletes(t *testing.T) {
+	ctx := context.Background()
+	fx := newFormFixture()
+
+	if err := fx.form.Start(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{"Ann", "30"} {
+		if ok, err := fx.form.Handle(ctx, 1, input); err != nil || !ok {
+			t.Fatalf("expected handled input %q, got %t, %v", input, ok, err)
+		}
+	}
+
+	if len(fx.completed) != 1 || fx.completed[0] != 1 {
+		t.Fatalf("expected completion for user 1, got %v", fx.completed)
+	}
+	if len(fx.prompts) != 2 || fx.prompts[0] != "name?" || fx.prompts[1] != "age?" {
+		t.Fatalf("expected both prompts, got %v", fx.prompts)
+	}
+	if name, _ := fx.fsm.Get(1, "name"); name != "Ann" {
+		t.Fatalf("expected name Ann, got %v", name)
+	}
+	if age, _ := fx.fsm.Get(1, "age"); age != 30 {
+		t.Fatalf("expected age 30, got %v", age)
+	}
+	if got := mustState(t, fx.fsm, 1); got != "idle" {
+		t.Fatalf("expected reset to idle, got %s", got)
+	}
+}
+
+func TestFormValidationFailure(t *testing.T) {
+	ctx := context.Background()
+	fx := newFormFixture()
+
+	if err := fx.form.Start(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fx.form.Handle(ctx, 1, "Ann"); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := fx.form.Handle(ctx, 1, "thirty")
+	if !ok || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %t, %v", ok, err)
+	}
+	if got := mustState(t, fx.fsm, 1); got != "age" {
+		t.Fatalf("expected to stay on age, got %s", got)
+	}
+	if len(fx.completed) != 0 {
+		t.Fatalf("expected no completion, got %v", fx.completed)
+	}
+}
+
+func TestFormCancel(t *testing.T) {
+	ctx := context.Background()
+	fx := newFormFixture()
+
+	if err := fx.form.Start(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := fx.form.Handle(ctx, 1, CancelInput); err != nil || !ok {
+		t.Fatalf("expected handled cancel, got %t, %v", ok, err)
+	}
+	if got := mustState(t, fx.fsm, 1); got != "idle" {
+		t.Fatalf("expected reset to idle, got %s", got)
+	}
+}
+
+func TestFormStepPromptsOnPlainTransition(t *testing.T) {
+	fx := newFormFixture()
+	if err := fx.fsm.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fx.fsm.Transition(context.Background(), 1, "age"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fx.prompts) != 1 || fx.prompts[0] != "age?" {
+		t.Fatalf("expected age prompt, got %v", fx.prompts)
+	}
+}
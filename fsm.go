@@ -2,21 +2,90 @@ package fsm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
 )
 
 // StateID is a type for state identifier
 type StateID string
 
-// Callback is a function that will be called on state transition
+// Callback is a function that will be called on state transition.
+// It's called without the user's lock, so it may call Transition for the same user to chain to the next state,
+// returning the chained error rolls back both transitions. Returning ErrStay keeps the previous state, see Transition
 type Callback func(ctx context.Context, args ...any) error
 
+// TransitionObserverCallback is a function that will be called after a transition with both its endpoints
+type TransitionObserverCallback func(ctx context.Context, userID int64, from, to StateID, args ...any) error
+
+// BeforeTransitionHook is a function that will be called before the state of the user is changed
+type BeforeTransitionHook func(ctx context.Context, userID int64, from, to StateID) error
+
+// AfterTransitionHook is a function that will be called after a transition with its resulting error
+type AfterTransitionHook func(ctx context.Context, userID int64, from, to StateID, err error)
+
 // FSM is a finite state machine
 type FSM[K comparable, V any] struct {
 	initialStateID StateID
 	callbacks      map[StateID]Callback
 	userStates     UserStateStorage
 	storage        DataStorage[K, V]
+	locales        LocaleStorage
+
+	idempotencyWindow int
+	callbackContext   func(parent context.Context) (context.Context, context.CancelFunc)
+	clock             Clock
+	rateLimits        map[StateID]*rateLimiter
+	stateMeta         map[StateID]map[string]any
+	explicitInit      bool
+	explicitStart     bool
+	initialStateFunc  func(userID int64) StateID
+	recoveryState     StateID
+	noInitialCallback bool
+	maxDepth          int
+	proposalTTL       time.Duration
+	runInitialOnSeed  bool
+	defaultLocale     string
+	authorizers       map[StateID]Authorizer
+	regions           map[string]UserStateStorage
+	observers         map[StateID][]TransitionObserverCallback
+	globalObservers   []TransitionObserverCallback
+	validators        map[K]func(V) error
+	randomTransitions map[StateID][]randomTarget
+	variantKeys       func(from StateID) K
+	rand              *rand.Rand
+	callbackSlots     chan struct{}
+	routes            map[routeKey]StateID
+	beforeHooks       []BeforeTransitionHook
+	afterHooks        []AfterTransitionHook
+	queues            *userQueues
+	argTypes          map[StateID][]reflect.Type
+	sticky            map[StateID]bool
+	maxKeys           int
+	deferred          map[StateID]bool
+	errorHandler      ErrorHandler
+	patterns          []callbackPattern
+	encode            ValueTransformer[V]
+	decode            ValueTransformer[V]
+
+	cbMu       sync.RWMutex
+	locks      userLocks
+	mu         sync.Mutex
+	closed     bool
+	inflight   sync.WaitGroup
+	background sync.WaitGroup
+	processed  *processedKeys
+	seqs       map[int64]uint64
+	visits     map[int64]map[StateID]int
+	proposals  map[int64]proposal
+	// proposalSweepAt is the number of proposals at which expired ones are swept
+	proposalSweepAt int
+	counters        counters
 }
 
 // UserStateStorage is an interface for user state storage
@@ -26,6 +95,11 @@ type UserStateStorage interface {
 	Get(userID int64) (StateID, error)
 }
 
+// UserStateEnumerator is an optional interface for user state storages that can list all known users
+type UserStateEnumerator interface {
+	Users() ([]int64, error)
+}
+
 // DataStorage is an interface for data storage
 type DataStorage[K comparable, V any] interface {
 	Set(userID int64, key K, value V) error
@@ -33,6 +107,59 @@ type DataStorage[K comparable, V any] interface {
 	Delete(userID int64, key K) error
 }
 
+// DataEnumerator is an optional interface for data storages that can return all user's data
+type DataEnumerator[K comparable, V any] interface {
+	All(userID int64) (map[K]V, error)
+}
+
+// DataLookuper is an optional interface for data storages that can tell a missing key from a zero value
+type DataLookuper[K comparable, V any] interface {
+	Lookup(userID int64, key K) (V, bool, error)
+}
+
+// DataGetDeleter is an optional interface for data storages that can get and delete a key in one atomic step
+type DataGetDeleter[K comparable, V any] interface {
+	GetAndDelete(userID int64, key K) (V, bool, error)
+}
+
+// DataCounter is an optional interface for data storages that can count user's keys
+type DataCounter interface {
+	Count(userID int64) (int, error)
+}
+
+// DataLister is an optional interface for data storages that can keep ordered lists of values under keys
+type DataLister[K comparable, V any] interface {
+	Append(userID int64, key K, value V) error
+	List(userID int64, key K) ([]V, error)
+	// Lists returns all user's lists
+	Lists(userID int64) (map[K][]V, error)
+	// SetList replaces user's list, an empty list deletes it
+	SetList(userID int64, key K, list []V) error
+}
+
+// DataClearer is an optional interface for data storages that can delete all user's data at once
+type DataClearer interface {
+	Clear(userID int64) error
+}
+
+// Flusher is an optional interface for storages that buffer writes
+type Flusher interface {
+	Flush() error
+}
+
+// Pinger is an optional interface for storages that can check their availability
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Warmer is an optional interface for caching storages that can preload users in bulk
+type Warmer interface {
+	Warm(ctx context.Context, userIDs []int64) error
+}
+
+// defaultMaxTransitionDepth is a default limit of chained transitions
+const defaultMaxTransitionDepth = 32
+
 // New creates a new FSM
 func New[K comparable, V any](initialStateName StateID, callbacks map[StateID]Callback, opts ...Option[K, V]) *FSM[K, V] {
 	s := &FSM[K, V]{
@@ -40,113 +167,1308 @@ func New[K comparable, V any](initialStateName StateID, callbacks map[StateID]Ca
 		callbacks:      make(map[StateID]Callback),
 		userStates:     initialUserStateStorage(),
 		storage:        initialDataStorage[K, V](),
-	}
+		locales:        initialLocaleStorage(),
 
-	for stateID, callback := range callbacks {
-		s.callbacks[stateID] = callback
+		idempotencyWindow: defaultIdempotencyWindow,
+		maxDepth:          defaultMaxTransitionDepth,
+		proposalTTL:       defaultProposalTTL,
+		clock:             systemClock{},
+		rateLimits:        make(map[StateID]*rateLimiter),
+		stateMeta:         make(map[StateID]map[string]any),
+		regions:           make(map[string]UserStateStorage),
+		observers:         make(map[StateID][]TransitionObserverCallback),
+		validators:        make(map[K]func(V) error),
+		randomTransitions: make(map[StateID][]randomTarget),
+		routes:            make(map[routeKey]StateID),
+		argTypes:          make(map[StateID][]reflect.Type),
+		sticky:            make(map[StateID]bool),
+		deferred:          make(map[StateID]bool),
+		authorizers:       make(map[StateID]Authorizer),
+		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		processed:         newProcessedKeys(),
+		seqs:              make(map[int64]uint64),
+		visits:            make(map[int64]map[StateID]int),
+		proposals:         make(map[int64]proposal),
 	}
 
+	s.AddCallbacks(callbacks)
+
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	s.wrapStorage()
+
 	return s
 }
 
-// AddCallback adds a callback for a state
+// wrapStorage wraps data storage with the value transformers set by WithValueTransformer
+func (f *FSM[K, V]) wrapStorage() {
+	if f.encode == nil || f.decode == nil {
+		return
+	}
+
+	f.storage = &transformedDataStorage[K, V]{
+		storage: f.storage,
+		encode:  f.encode,
+		decode:  f.decode,
+	}
+}
+
+// InitialState returns the initial state of FSM
+func (f *FSM[K, V]) InitialState() StateID {
+	return f.initialStateID
+}
+
+// initialState returns the initial state of the user, computed by WithInitialStateFunc if it's set
+func (f *FSM[K, V]) initialState(userID int64) StateID {
+	if f.initialStateFunc != nil {
+		return f.initialStateFunc(userID)
+	}
+
+	return f.initialStateID
+}
+
+// AddCallback adds a callback for a state, a nil callback removes the state's callback.
+// It's safe to call concurrently with transitions
 func (f *FSM[K, V]) AddCallback(stateID StateID, callback Callback) {
-	f.callbacks[stateID] = callback
+	f.cbMu.Lock()
+	defer f.cbMu.Unlock()
+
+	f.addCallback(stateID, callback)
 }
 
-// AddCallbacks adds callbacks for states
+// AddCallbacks adds callbacks for states, nil callbacks remove the states' callbacks.
+// The whole batch is applied at once, concurrent transitions see either none or all of it
 func (f *FSM[K, V]) AddCallbacks(cb map[StateID]Callback) {
+	f.cbMu.Lock()
+	defer f.cbMu.Unlock()
+
 	for stateID, callback := range cb {
-		f.callbacks[stateID] = callback
+		f.addCallback(stateID, callback)
+	}
+}
+
+// addCallback adds a callback for a state, cbMu must be held
+func (f *FSM[K, V]) addCallback(stateID StateID, callback Callback) {
+	delete(f.argTypes, stateID)
+	delete(f.deferred, stateID)
+	if callback == nil {
+		delete(f.callbacks, stateID)
+		return
 	}
+
+	f.callbacks[stateID] = callback
 }
 
-// Transition transitions the user to a new state
+// SetStateMeta sets presentation metadata of a state, it doesn't affect transitions
+func (f *FSM[K, V]) SetStateMeta(stateID StateID, meta map[string]any) {
+	f.stateMeta[stateID] = meta
+}
+
+// StateMeta returns metadata of a state
+func (f *FSM[K, V]) StateMeta(stateID StateID) (map[string]any, bool) {
+	meta, ok := f.stateMeta[stateID]
+
+	return meta, ok
+}
+
+// AddTransitionObserver adds an observer called after every transition to the state
+func (f *FSM[K, V]) AddTransitionObserver(stateID StateID, observer TransitionObserverCallback) {
+	f.observers[stateID] = append(f.observers[stateID], observer)
+}
+
+// Transition transitions the user to a new state.
+// Before hooks are called under the user's lock before the state is written, their error aborts the transition.
+// The callback is called after the lock is released, its error rolls the state back. ErrStay of the callback
+// restores the previous state and data written by CommitAndTransition without failing, the transition isn't
+// observed or counted in Seq, VisitCount and Metrics. Then the observers of the new state are called, then the
+// global ones, their error is returned without a rollback. After hooks are called last with the result.
+// A rate limited callback is skipped, the state is set and ErrRateLimited is returned.
+// With VersionedUserStateStorage a concurrent change by another FSM instance fails with ErrConcurrentModification
 func (f *FSM[K, V]) Transition(ctx context.Context, userID int64, stateID StateID, args ...any) error {
-	oldStateID, err := f.userStates.Get(userID)
+	err := f.transition(ctx, transitionRequest{
+		states: f.userStates,
+		userID: userID,
+		next:   toState(stateID),
+		args:   args,
+	})
+
+	return wrapError("transition", userID, stateID, err)
+}
+
+// TransitionFunc transitions the user to a state computed by next from the current one like Transition.
+// Reading the current state, calling next and writing the new state happen under the user's lock
+func (f *FSM[K, V]) TransitionFunc(ctx context.Context, userID int64, next func(current StateID) (StateID, error), args ...any) error {
+	err := f.transition(ctx, transitionRequest{
+		states: f.userStates,
+		userID: userID,
+		next:   next,
+		args:   args,
+	})
+
+	return wrapError("transition", userID, "", err)
+}
+
+// CanTransition checks whether the user can be transitioned to the state without transitioning it.
+// It returns false and the reason: ErrClosed, ErrNoUserState, ErrUnauthorized, ErrRateLimited or ErrVetoed wrapping
+// the before hook's error. Before hooks are called, so hooks with side effects will run them.
+// Callback args and the callback itself aren't checked
+func (f *FSM[K, V]) CanTransition(ctx context.Context, userID int64, stateID StateID) (bool, error) {
+	err := f.canTransition(ctx, userID, stateID)
+	if err != nil {
+		return false, wrapError("can transition", userID, stateID, err)
+	}
+
+	return true, nil
+}
+
+// canTransition runs the checks of CanTransition
+func (f *FSM[K, V]) canTransition(ctx context.Context, userID int64, stateID StateID) error {
+	f.mu.Lock()
+	closed := f.closed
+	f.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	unlock := f.locks.lock(userID)
+	current, err := f.userStates.Get(userID)
 	if err != nil {
+		unlock()
 		return fmt.Errorf("failed to get user state: %w", err)
 	}
 
-	err = f.userStates.Set(userID, stateID)
+	if current == stateID && f.sticky[stateID] {
+		unlock()
+		return nil
+	}
+
+	err = f.authorize(userID, stateID)
+	if err == nil {
+		err = f.runBeforeHooks(ctx, userID, current, stateID)
+	}
+	unlock()
 	if err != nil {
-		return fmt.Errorf("failed to set user state: %w", err)
+		return err
 	}
 
-	cb, okCb := f.callbacks[stateID]
-	if okCb {
-		err = cb(ctx, args...)
-		if err != nil {
-			err = f.userStates.Set(userID, oldStateID)
-			if err != nil {
-				return fmt.Errorf("failed to set user state: %w", err)
-			}
+	if _, ok := f.callback(stateID); ok && !f.peekCallback(userID, stateID) {
+		return ErrRateLimited
+	}
+
+	return nil
+}
 
-			return fmt.Errorf("failed to execute callback: %w", err)
+// runBeforeHooks calls the before hooks, the first error vetoes the transition
+func (f *FSM[K, V]) runBeforeHooks(ctx context.Context, userID int64, from, to StateID) error {
+	for _, hook := range f.beforeHooks {
+		if err := hook(ctx, userID, from, to); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
 		}
 	}
 
 	return nil
 }
 
-// Current returns the current state of the user
-func (f *FSM[K, V]) Current(userID int64) (StateID, error) {
-	ok, err := f.userStates.Exists(userID)
+// transitionRequest is a request of a single transition
+type transitionRequest struct {
+	// states is a user state storage to transition in
+	states UserStateStorage
+	userID int64
+	// next computes the new state from the current one
+	next func(current StateID) (StateID, error)
+	// commit is optional, it writes data together with the state under the user's lock,
+	// the returned undo function reverts the written data
+	commit func() (undo func() error, err error)
+	args   []any
+}
+
+// toState returns a next function of a transition to the fixed state
+func toState(stateID StateID) func(StateID) (StateID, error) {
+	return func(StateID) (StateID, error) {
+		return stateID, nil
+	}
+}
+
+// depthKey is a context key holding the depth of chained transitions
+type depthKey struct{}
+
+// userIDKey is a context key holding the user whose callback is called
+type userIDKey struct{}
+
+// UserIDFromContext returns the user whose callback is called with ctx, false outside of callbacks
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int64)
+
+	return userID, ok
+}
+
+// transition transitions the user by the request
+func (f *FSM[K, V]) transition(ctx context.Context, req transitionRequest) error {
+	depth, _ := ctx.Value(depthKey{}).(int)
+	if f.maxDepth > 0 && depth >= f.maxDepth {
+		return fmt.Errorf("%w: depth: %d", ErrTransitionLoop, depth)
+	}
+	ctx = context.WithValue(ctx, depthKey{}, depth+1)
+
+	if f.queues != nil {
+		return f.queues.do(ctx, req.userID, func(ctx context.Context) error {
+			return f.transitionNow(ctx, req)
+		})
+	}
+
+	return f.transitionNow(ctx, req)
+}
+
+// transitionNow transitions the user by the request in the calling goroutine
+func (f *FSM[K, V]) transitionNow(ctx context.Context, req transitionRequest) error {
+	err := f.begin()
 	if err != nil {
-		return "", fmt.Errorf("failed to check user state: %w", err)
+		return err
 	}
-	if !ok {
-		err = f.userStates.Set(userID, f.initialStateID)
+	defer f.inflight.Done()
+
+	err = f.apply(ctx, req)
+	if errors.Is(err, ErrStay) {
+		return nil
+	}
+	if errors.Is(err, errNoRoute) {
+		return err
+	}
+	f.count(err)
+
+	return err
+}
+
+// apply changes the user's state and enters the new one
+func (f *FSM[K, V]) apply(ctx context.Context, req transitionRequest) error {
+	unlock := f.locks.lock(req.userID)
+	oldStateID, version, err := getState(req.states, req.userID)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	stateID, err := req.next(oldStateID)
+	if err != nil {
+		unlock()
+		return fmt.Errorf("failed to compute next state: %w", err)
+	}
+
+	if oldStateID == stateID && f.sticky[stateID] {
+		if req.commit != nil {
+			_, err = req.commit()
+		}
+		unlock()
+		return err
+	}
+
+	err = f.checkArgs(stateID, req.args)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	err = f.authorize(req.userID, stateID)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	err = f.runBeforeHooks(ctx, req.userID, oldStateID, stateID)
+	if err != nil {
+		unlock()
+		return err
+	}
+
+	undo := func() error { return nil }
+	if req.commit != nil {
+		undo, err = req.commit()
 		if err != nil {
-			return "", fmt.Errorf("failed to set user state to initial: %w", err)
+			unlock()
+			return err
 		}
+	}
 
-		return f.initialStateID, nil
+	_, err = setState(req.states, req.userID, stateID, version)
+	if err != nil {
+		err = errors.Join(err, undo())
+	}
+	unlock()
+	if err != nil {
+		return err
 	}
 
-	state, err := f.userStates.Get(userID)
+	err = f.enter(ctx, req, oldStateID, stateID, undo)
+
+	to, hookErr := stateID, err
+	if errors.Is(err, ErrStay) {
+		to, hookErr = oldStateID, nil
+	}
+	for _, hook := range f.afterHooks {
+		hook(ctx, req.userID, oldStateID, to, hookErr)
+	}
+
+	return err
+}
+
+// count updates the transition counters by the transition result
+func (f *FSM[K, V]) count(err error) {
 	if err != nil {
-		return "", fmt.Errorf("failed to get user state: %w", err)
+		f.counters.failures.Add(1)
+	} else {
+		f.counters.transitions.Add(1)
 	}
+}
 
-	return state, nil
+// enter calls the callback of the new state and the observers,
+// the state and the committed data are rolled back if the callback fails or returns ErrStay
+func (f *FSM[K, V]) enter(ctx context.Context, req transitionRequest, oldStateID, stateID StateID, undo func() error) error {
+	ctx = context.WithValue(ctx, userIDKey{}, req.userID)
+
+	cb, okCb := f.callback(stateID)
+	if okCb {
+		if !f.allowCallback(req.userID, stateID) {
+			return fmt.Errorf("%w: userID: %d, state: %s", ErrRateLimited, req.userID, stateID)
+		}
+
+		var err error
+		if f.isDeferred(stateID) {
+			f.runDeferred(ctx, req.userID, stateID, cb, req.args...)
+		} else {
+			err = f.runCallback(ctx, cb, req.args...)
+		}
+		if errors.Is(err, ErrStay) {
+			if errRollback := f.rollback(req, oldStateID, stateID, undo); errRollback != nil {
+				return fmt.Errorf("failed to stay in previous state: %w", errRollback)
+			}
+
+			return ErrStay
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to execute callback: %w", err)
+			if errRollback := f.rollback(req, oldStateID, stateID, undo); errRollback != nil {
+				return errors.Join(err, errRollback)
+			}
+
+			if errors.Is(err, ErrCallbackPanic) && f.recoveryState != "" && stateID != f.recoveryState {
+				err = errors.Join(err, f.transition(ctx, transitionRequest{
+					states: req.states,
+					userID: req.userID,
+					next:   toState(f.recoveryState),
+				}))
+			}
+
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	f.seqs[req.userID]++
+	if f.visits[req.userID] == nil {
+		f.visits[req.userID] = make(map[StateID]int)
+	}
+	f.visits[req.userID][stateID]++
+	f.mu.Unlock()
+
+	return f.notifyObservers(ctx, req.userID, oldStateID, stateID, req.args...)
 }
 
-// Reset resets the state of the user to the initial state
-func (f *FSM[K, V]) Reset(userID int64) error {
-	return f.userStates.Set(userID, f.initialStateID)
+// rollback restores the user's old state and the committed data after the callback of the new state failed.
+// The current version is read under the lock, so rollbacks of transitions chained from the callback
+// don't make it fail. If the user isn't in the new state anymore, e.g. a chained transition succeeded,
+// the user is left where they are with the committed data
+func (f *FSM[K, V]) rollback(req transitionRequest, oldStateID, stateID StateID, undo func() error) error {
+	unlock := f.locks.lock(req.userID)
+	defer unlock()
+
+	current, version, err := getState(req.states, req.userID)
+	if err != nil {
+		return err
+	}
+	if current != stateID {
+		return nil
+	}
+
+	_, err = setState(req.states, req.userID, oldStateID, version)
+
+	return errors.Join(err, undo())
 }
 
-// Set sets a value to data storage by userID and comparable
-func (f *FSM[K, V]) Set(userID int64, key K, value V) error {
-	err := f.storage.Set(userID, key, value)
+// Refire calls the callback of the user's current state again without changing the state.
+// Transition observers aren't called and the sequence number isn't changed, ErrStay of the callback
+// is a success since the user is already in the state
+func (f *FSM[K, V]) Refire(ctx context.Context, userID int64, args ...any) error {
+	err := f.begin()
 	if err != nil {
-		return fmt.Errorf("failed to set user data: %w", err)
+		return wrapError("refire", userID, "", err)
+	}
+	defer f.inflight.Done()
+
+	stateID, err := f.userStates.Get(userID)
+	if err != nil {
+		return wrapError("refire", userID, "", fmt.Errorf("failed to get user state: %w", err))
+	}
+
+	cb, ok := f.callback(stateID)
+	if !ok {
+		return nil
+	}
+
+	err = f.checkArgs(stateID, args)
+	if err != nil {
+		return wrapError("refire", userID, stateID, err)
+	}
+
+	if !f.allowCallback(userID, stateID) {
+		return wrapError("refire", userID, stateID, ErrRateLimited)
+	}
+
+	ctx = context.WithValue(ctx, userIDKey{}, userID)
+
+	if f.isDeferred(stateID) {
+		f.runDeferred(ctx, userID, stateID, cb, args...)
+		return nil
+	}
+
+	err = f.runCallback(ctx, cb, args...)
+	if err != nil && !errors.Is(err, ErrStay) {
+		return wrapError("refire", userID, stateID, fmt.Errorf("failed to execute callback: %w", err))
 	}
 
 	return nil
 }
 
-// Get gets a value from data storage by userID and comparable
-func (f *FSM[K, V]) Get(userID int64, key K) (V, error) {
-	v, err := f.storage.Get(userID, key)
+// notifyObservers calls the observers of the to state, then the global observers
+func (f *FSM[K, V]) notifyObservers(ctx context.Context, userID int64, from, to StateID, args ...any) error {
+	observers := slices.Concat(f.observers[to], f.globalObservers)
+
+	for _, observer := range observers {
+		err := observer(ctx, userID, from, to, args...)
+		if err != nil {
+			return fmt.Errorf("failed to execute transition observer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// callbackSlotKey is a context key marking that the callback already holds a concurrency slot
+type callbackSlotKey struct{}
+
+// runCallback runs the callback under the context decorated by WithDefaultCallbackContext.
+// With WithCallbackConcurrency it waits for a free slot first, transitions chained from
+// the callback reuse its slot
+func (f *FSM[K, V]) runCallback(ctx context.Context, cb Callback, args ...any) (err error) {
+	if f.callbackSlots != nil && ctx.Value(callbackSlotKey{}) == nil {
+		select {
+		case f.callbackSlots <- struct{}{}:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for callback slot: %w", ctx.Err())
+		}
+		defer func() { <-f.callbackSlots }()
+
+		ctx = context.WithValue(ctx, callbackSlotKey{}, struct{}{})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			f.counters.panics.Add(1)
+			if f.recoveryState == "" {
+				panic(r)
+			}
+
+			err = fmt.Errorf("%w: %v", ErrCallbackPanic, r)
+		}
+	}()
+
+	if f.callbackContext != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = f.callbackContext(ctx)
+		defer cancel()
+	}
+
+	return cb(ctx, args...)
+}
+
+// Seq returns the sequence number of the user's last successful transition, 0 if there were none.
+// Sequence numbers are kept in memory and start over when FSM is recreated or the user is replaced or cleared
+func (f *FSM[K, V]) Seq(userID int64) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.seqs[userID], nil
+}
+
+// VisitCount returns how many times the user has successfully entered the state, including visits counted by OnVisit.
+// Visits are kept in memory and start over when FSM is recreated or the user is replaced or cleared,
+// entries into regions are counted too
+func (f *FSM[K, V]) VisitCount(userID int64, stateID StateID) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.visits[userID][stateID], nil
+}
+
+// OnVisit counts a visit of the user to the state and reports whether it's the nth one.
+// The visit is counted and compared atomically, so it returns true exactly once for n under concurrent calls.
+// Transitions count visits by themselves, OnVisit is meant for re-entries without a transition,
+// e.g. Refire after invalid input
+func (f *FSM[K, V]) OnVisit(userID int64, stateID StateID, n int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.visits[userID] == nil {
+		f.visits[userID] = make(map[StateID]int)
+	}
+	f.visits[userID][stateID]++
+
+	return f.visits[userID][stateID] == n, nil
+}
+
+// forget drops the user's in-memory bookkeeping: sequence number, visits, idempotency keys,
+// a pending proposal and rate limits
+func (f *FSM[K, V]) forget(userID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.seqs, userID)
+	delete(f.visits, userID)
+	delete(f.proposals, userID)
+	f.processed.forget(userID)
+	for _, limiter := range f.rateLimits {
+		delete(limiter.buckets, userID)
+	}
+}
+
+// Current returns the current state of the user.
+// An unknown user is seeded with the initial state, unless WithExplicitInit is set,
+// in this case ErrNoUserState is returned until Init is called.
+// With WithExplicitStart the initial state is returned for an unknown user without seeding it
+func (f *FSM[K, V]) Current(userID int64) (StateID, error) {
+	if f.explicitInit {
+		state, err := f.userStates.Get(userID)
+		if err != nil {
+			return "", wrapError("current", userID, "", fmt.Errorf("failed to get user state: %w", err))
+		}
+
+		return state, nil
+	}
+
+	if f.explicitStart {
+		stateID, err := f.peek(userID)
+
+		return stateID, wrapError("current", userID, "", err)
+	}
+
+	stateID, seeded, err := f.seed(f.userStates, userID)
+	if err == nil && seeded && f.runInitialOnSeed {
+		err = f.runInitial(userID, stateID)
+	}
+
+	return stateID, wrapError("current", userID, "", err)
+}
+
+// runInitial enters the initial state of the user seeded by Current the way a transition does
+func (f *FSM[K, V]) runInitial(userID int64, stateID StateID) error {
+	err := f.begin()
 	if err != nil {
-		var empty V
-		return empty, fmt.Errorf("failed to get user data: %w", err)
+		return err
 	}
+	defer f.inflight.Done()
 
-	return v, nil
+	err = f.checkArgs(stateID, nil)
+	if err != nil {
+		return err
+	}
+
+	req := transitionRequest{
+		states: f.userStates,
+		userID: userID,
+		next:   toState(stateID),
+	}
+	err = f.enter(context.Background(), req, stateID, stateID, func() error { return nil })
+	if errors.Is(err, ErrStay) {
+		return nil
+	}
+
+	return err
 }
 
-// Delete deletes a value from data storage by userID and comparable
-func (f *FSM[K, V]) Delete(userID int64, key K) error {
-	err := f.storage.Delete(userID, key)
+// peek returns the current state of the user, the initial one for an unknown user
+func (f *FSM[K, V]) peek(userID int64) (StateID, error) {
+	ok, err := f.userStates.Exists(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check user state: %w", err)
+	}
+	if !ok {
+		return f.initialState(userID), nil
+	}
+
+	state, err := f.userStates.Get(userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete user data: %w", err)
+		return "", fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	return state, nil
+}
+
+// Start enters the user into the machine: an unknown user is seeded, then the user is transitioned
+// to the initial state and its callback is called. A known user is moved back to the initial state
+func (f *FSM[K, V]) Start(ctx context.Context, userID int64, args ...any) error {
+	_, _, err := f.seed(f.userStates, userID)
+	if err == nil {
+		err = f.transition(ctx, transitionRequest{
+			states: f.userStates,
+			userID: userID,
+			next:   toState(f.initialState(userID)),
+			args:   args,
+		})
+	}
+
+	return wrapError("start", userID, f.initialState(userID), err)
+}
+
+// Init seeds the initial state for an unknown user, it doesn't change the state of a known user
+func (f *FSM[K, V]) Init(userID int64) error {
+	_, _, err := f.seed(f.userStates, userID)
+
+	return wrapError("init", userID, f.initialState(userID), err)
+}
+
+// seed returns the current state of the user in the given storage, setting the initial state for an unknown user.
+// It returns true if the user was seeded
+func (f *FSM[K, V]) seed(states UserStateStorage, userID int64) (StateID, bool, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	ok, err := states.Exists(userID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check user state: %w", err)
+	}
+	if !ok {
+		initial := f.initialState(userID)
+		err = states.Set(userID, initial)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to set user state to initial: %w", err)
+		}
+
+		return initial, true, nil
+	}
+
+	state, err := states.Get(userID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	return state, false, nil
+}
+
+// Reset resets the state of the user to the initial state
+func (f *FSM[K, V]) Reset(userID int64) error {
+	initial := f.initialState(userID)
+	err := f.userStates.Set(userID, initial)
+	if err != nil {
+		return wrapError("reset", userID, initial, fmt.Errorf("failed to set user state: %w", err))
+	}
+
+	return nil
+}
+
+// ResetAll resets every known user to the initial state without firing callbacks, regions the user
+// has entered are reset too. If filter isn't nil, only users whose current state it accepts are reset.
+// It returns the number of reset users. User state storage must implement UserStateEnumerator
+func (f *FSM[K, V]) ResetAll(ctx context.Context, filter func(stateID StateID) bool) (int, error) {
+	userIDs, err := f.users()
+	if err != nil {
+		return 0, wrapError("reset all", 0, "", err)
+	}
+
+	var n int
+	for _, userID := range userIDs {
+		if err = ctx.Err(); err != nil {
+			return n, wrapError("reset all", 0, "", err)
+		}
+
+		ok, err := f.resetIf(userID, filter)
+		if err != nil {
+			return n, wrapError("reset all", userID, f.initialState(userID), err)
+		}
+		if ok {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// resetIf resets the user under the lock if filter accepts the current state
+func (f *FSM[K, V]) resetIf(userID int64, filter func(stateID StateID) bool) (bool, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	stateID, err := f.userStates.Get(userID)
+	if errors.Is(err, ErrNoUserState) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	if filter != nil && !filter(stateID) {
+		return false, nil
+	}
+
+	err = f.userStates.Set(userID, f.initialState(userID))
+	if err != nil {
+		return false, fmt.Errorf("failed to set user state: %w", err)
+	}
+
+	regions, err := f.regionStates(userID)
+	if err != nil {
+		return false, err
+	}
+	for region := range regions {
+		regions[region] = f.initialState(userID)
+	}
+
+	err = f.setRegionStates(userID, regions)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RenameState moves every user in the from state to the to state without firing callbacks.
+// Each user is moved under the user's lock, users removed meanwhile are skipped.
+// It returns the number of migrated users. User state storage must implement UserStateEnumerator
+func (f *FSM[K, V]) RenameState(ctx context.Context, from, to StateID) (int, error) {
+	userIDs, err := f.users()
+	if err != nil {
+		return 0, wrapError("rename state", 0, to, err)
+	}
+
+	var n int
+	for _, userID := range userIDs {
+		if err = ctx.Err(); err != nil {
+			return n, wrapError("rename state", 0, to, err)
+		}
+
+		ok, err := f.renameIf(userID, from, to)
+		if err != nil {
+			return n, wrapError("rename state", userID, to, err)
+		}
+		if ok {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// renameIf moves the user to the to state under the lock if the user is in the from state
+func (f *FSM[K, V]) renameIf(userID int64, from, to StateID) (bool, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	stateID, err := f.userStates.Get(userID)
+	if errors.Is(err, ErrNoUserState) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	if stateID != from {
+		return false, nil
+	}
+
+	err = f.userStates.Set(userID, to)
+	if err != nil {
+		return false, fmt.Errorf("failed to set user state: %w", err)
+	}
+
+	return true, nil
+}
+
+// CollectData returns the key's value for every user in the state, users without the key are omitted.
+// It reads state of all users, so it's O(users). User state storage must implement UserStateEnumerator
+// and data storage must implement DataLookuper
+func (f *FSM[K, V]) CollectData(stateID StateID, key K) (map[int64]V, error) {
+	userIDs, err := f.users()
+	if err != nil {
+		return nil, wrapError("collect data", 0, stateID, err)
+	}
+
+	data := make(map[int64]V)
+	for _, userID := range userIDs {
+		userStateID, err := f.userStates.Get(userID)
+		if err != nil {
+			return nil, wrapError("collect data", userID, stateID, fmt.Errorf("failed to get user state: %w", err))
+		}
+		if userStateID != stateID {
+			continue
+		}
+
+		v, ok, err := f.lookup(userID, key)
+		if err != nil {
+			return nil, wrapError("collect data", userID, stateID, err)
+		}
+		if ok {
+			data[userID] = v
+		}
+	}
+
+	return data, nil
+}
+
+// Range calls fn for every known user with the user's state, it stops early if fn returns false.
+// The list of users is taken at once, while states are read during the iteration and may change
+// due to concurrent transitions. User state storage must implement UserStateEnumerator
+func (f *FSM[K, V]) Range(fn func(userID int64, state StateID) bool) error {
+	userIDs, err := f.users()
+	if err != nil {
+		return wrapError("range", 0, "", err)
+	}
+
+	for _, userID := range userIDs {
+		stateID, err := f.userStates.Get(userID)
+		if errors.Is(err, ErrNoUserState) {
+			continue
+		}
+		if err != nil {
+			return wrapError("range", userID, "", fmt.Errorf("failed to get user state: %w", err))
+		}
+
+		if !fn(userID, stateID) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// States returns the states of the given users, unknown users are omitted.
+// Unlike Current, it never seeds the initial state, so it doesn't write to user state storage
+func (f *FSM[K, V]) States(userIDs []int64) (map[int64]StateID, error) {
+	states := make(map[int64]StateID, len(userIDs))
+	for _, userID := range userIDs {
+		ok, err := f.userStates.Exists(userID)
+		if err != nil {
+			return nil, wrapError("states", userID, "", fmt.Errorf("failed to check user state: %w", err))
+		}
+		if !ok {
+			continue
+		}
+
+		states[userID], err = f.userStates.Get(userID)
+		if err != nil {
+			return nil, wrapError("states", userID, "", fmt.Errorf("failed to get user state: %w", err))
+		}
+	}
+
+	return states, nil
+}
+
+// users returns all known users from user state storage
+func (f *FSM[K, V]) users() ([]int64, error) {
+	enumerator, ok := f.userStates.(UserStateEnumerator)
+	if !ok {
+		return nil, fmt.Errorf("%w: user state storage can't enumerate users", ErrNotSupported)
+	}
+
+	userIDs, err := enumerator.Users()
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate users: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// AddDataValidator adds a validator for the key, Set returns the validator's error
+// and doesn't store the value if the validation fails
+func (f *FSM[K, V]) AddDataValidator(key K, validator func(V) error) {
+	f.validators[key] = validator
+}
+
+// Set sets a value to data storage by userID and comparable
+func (f *FSM[K, V]) Set(userID int64, key K, value V) error {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	return wrapError("set", userID, "", f.set(userID, key, value))
+}
+
+// set validates and sets a value to data storage, the user's lock must be held
+func (f *FSM[K, V]) set(userID int64, key K, value V) error {
+	if validator, ok := f.validators[key]; ok {
+		if err := validator(value); err != nil {
+			return fmt.Errorf("invalid user data: %w", err)
+		}
+	}
+
+	err := f.checkKeyLimit(userID, key)
+	if err != nil {
+		return err
+	}
+
+	err = f.storage.Set(userID, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to set user data: %w", err)
+	}
+
+	return nil
+}
+
+// checkKeyLimit returns ErrTooManyKeys if the key is new and the user already has WithMaxKeysPerUser keys
+func (f *FSM[K, V]) checkKeyLimit(userID int64, key K) error {
+	if f.maxKeys <= 0 {
+		return nil
+	}
+
+	_, ok, err := f.lookup(userID, key)
+	if err != nil || ok {
+		return err
+	}
+
+	n, err := f.keyCount(userID)
+	if err != nil {
+		return err
+	}
+
+	if n >= f.maxKeys {
+		return fmt.Errorf("%w: limit: %d", ErrTooManyKeys, f.maxKeys)
+	}
+
+	return nil
+}
+
+// KeyCount returns the number of distinct keys the user has stored by Set, 0 for unknown users, lists aren't counted.
+// Data storage must implement DataCounter
+func (f *FSM[K, V]) KeyCount(userID int64) (int, error) {
+	n, err := f.keyCount(userID)
+	if err != nil {
+		return 0, wrapError("key count", userID, "", err)
+	}
+
+	return n, nil
+}
+
+// keyCount returns the number of user's keys in data storage
+func (f *FSM[K, V]) keyCount(userID int64) (int, error) {
+	counter, ok := f.storage.(DataCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: data storage can't count user data", ErrNotSupported)
+	}
+
+	n, err := counter.Count(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count user data: %w", err)
+	}
+
+	return n, nil
+}
+
+// Get gets a value from data storage by userID and comparable
+func (f *FSM[K, V]) Get(userID int64, key K) (V, error) {
+	v, err := f.storage.Get(userID, key)
+	if err != nil {
+		var empty V
+		return empty, wrapError("get", userID, "", fmt.Errorf("failed to get user data: %w", err))
+	}
+
+	return v, nil
+}
+
+// GetOrSet returns the existing value of the key or, if there is no such key, stores and returns
+// the value computed by fn. fn is called under the user's lock, so concurrent callers agree on one value,
+// fn may use FSM for other users but must not touch the same user.
+// Data storage must implement DataLookuper
+func (f *FSM[K, V]) GetOrSet(userID int64, key K, fn func() (V, error)) (V, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	v, ok, err := f.lookup(userID, key)
+	if err != nil || ok {
+		return v, wrapError("get or set", userID, "", err)
+	}
+
+	v, err = fn()
+	if err != nil {
+		var empty V
+		return empty, wrapError("get or set", userID, "", fmt.Errorf("failed to compute user data: %w", err))
+	}
+
+	err = f.set(userID, key, v)
+	if err != nil {
+		var empty V
+		return empty, wrapError("get or set", userID, "", err)
+	}
+
+	return v, nil
+}
+
+// lookup gets a value from data storage, it returns false if there is no such key
+func (f *FSM[K, V]) lookup(userID int64, key K) (V, bool, error) {
+	lookuper, ok := f.storage.(DataLookuper[K, V])
+	if !ok {
+		var empty V
+		return empty, false, fmt.Errorf("%w: data storage can't look up keys", ErrNotSupported)
+	}
+
+	v, ok, err := lookuper.Lookup(userID, key)
+	if err != nil {
+		var empty V
+		return empty, false, fmt.Errorf("failed to get user data: %w", err)
+	}
+
+	return v, ok, nil
+}
+
+// Delete deletes a value from data storage by userID and comparable
+func (f *FSM[K, V]) Delete(userID int64, key K) error {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	err := f.storage.Delete(userID, key)
+	if err != nil {
+		return wrapError("delete", userID, "", fmt.Errorf("failed to delete user data: %w", err))
+	}
+
+	return nil
+}
+
+// GetAndDelete gets a value and deletes it from data storage in one atomic step,
+// it returns false if there is no such key. Data storage must implement DataGetDeleter
+func (f *FSM[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	var empty V
+	getDeleter, ok := f.storage.(DataGetDeleter[K, V])
+	if !ok {
+		return empty, false, wrapError("get and delete", userID, "", fmt.Errorf("%w: data storage can't get and delete keys", ErrNotSupported))
+	}
+
+	v, ok, err := getDeleter.GetAndDelete(userID, key)
+	if err != nil {
+		return empty, false, wrapError("get and delete", userID, "", fmt.Errorf("failed to get and delete user data: %w", err))
+	}
+
+	return v, ok, nil
+}
+
+// Append appends a value to the user's list under the key, lists are kept apart from values set by Set.
+// Data storage must implement DataLister
+func (f *FSM[K, V]) Append(userID int64, key K, value V) error {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	lister, ok := f.storage.(DataLister[K, V])
+	if !ok {
+		return wrapError("append", userID, "", fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported))
+	}
+
+	if validator, ok := f.validators[key]; ok {
+		if err := validator(value); err != nil {
+			return wrapError("append", userID, "", fmt.Errorf("invalid user data: %w", err))
+		}
+	}
+
+	err := lister.Append(userID, key, value)
+	if err != nil {
+		return wrapError("append", userID, "", fmt.Errorf("failed to append user data: %w", err))
+	}
+
+	return nil
+}
+
+// List returns the user's list under the key in append order, empty if there is no such list.
+// Data storage must implement DataLister
+func (f *FSM[K, V]) List(userID int64, key K) ([]V, error) {
+	lister, ok := f.storage.(DataLister[K, V])
+	if !ok {
+		return nil, wrapError("list", userID, "", fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported))
+	}
+
+	list, err := lister.List(userID, key)
+	if err != nil {
+		return nil, wrapError("list", userID, "", fmt.Errorf("failed to get user list: %w", err))
+	}
+
+	return list, nil
+}
+
+// ClearData deletes all user's data keeping the user's state and forgets the user's sequence number, visits,
+// idempotency keys, proposal and rate limits. Data storage must implement DataClearer
+func (f *FSM[K, V]) ClearData(userID int64) error {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	err := f.clear(userID)
+	if err != nil {
+		return wrapError("clear data", userID, "", err)
+	}
+
+	f.forget(userID)
+
+	return nil
+}
+
+// clear deletes all user's data, the user's lock must be held
+func (f *FSM[K, V]) clear(userID int64) error {
+	clearer, ok := f.storage.(DataClearer)
+	if !ok {
+		return fmt.Errorf("%w: data storage can't clear user data", ErrNotSupported)
+	}
+
+	err := clearer.Clear(userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear user data: %w", err)
+	}
+
+	return nil
+}
+
+// Close waits for in-flight transitions and deferred callbacks until ctx is done, then flushes and closes
+// the storages implementing Flusher and io.Closer. Transitions after Close return ErrClosed
+func (f *FSM[K, V]) Close(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.inflight.Wait()
+		f.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return wrapError("close", 0, "", fmt.Errorf("failed to wait for in-flight transitions: %w", ctx.Err()))
+	}
+
+	var errs []error
+	for _, storage := range f.storages() {
+		if err := flush(storage); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush storage: %w", err))
+		}
+		if err := closeStorage(storage); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close storage: %w", err))
+		}
+	}
+
+	return wrapError("close", 0, "", errors.Join(errs...))
+}
+
+// WaitReady pings the storages implementing Pinger every interval until all of them are reachable
+// or ctx is done. The interval must be positive, otherwise ErrInvalidInput is returned
+func (f *FSM[K, V]) WaitReady(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return wrapError("wait ready", 0, "", fmt.Errorf("%w: non-positive ping interval %s", ErrInvalidInput, interval))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := f.ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return wrapError("wait ready", 0, "", fmt.Errorf("storages are not ready: %w", errors.Join(ctx.Err(), err)))
+		case <-ticker.C:
+		}
+	}
+}
+
+// Warm preloads state and data of the users into the storages implementing Warmer,
+// so the first access to the users doesn't hit the underlying backend
+func (f *FSM[K, V]) Warm(ctx context.Context, userIDs []int64) error {
+	for _, storage := range f.storages() {
+		if err := warm(ctx, storage, userIDs); err != nil {
+			return wrapError("warm", 0, "", fmt.Errorf("failed to warm storage: %w", err))
+		}
+	}
+
+	return nil
+}
+
+// ping pings the storages implementing Pinger
+func (f *FSM[K, V]) ping(ctx context.Context) error {
+	for _, storage := range f.storages() {
+		if err := ping(ctx, storage); err != nil {
+			return fmt.Errorf("failed to ping storage: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// storages returns all storages of FSM including the region ones
+func (f *FSM[K, V]) storages() []any {
+	storages := []any{f.userStates, f.storage, f.locales}
+
+	_, regions := f.regionStorages()
+	for _, region := range regions {
+		storages = append(storages, region)
+	}
+
+	return storages
+}
+
+// begin registers an in-flight transition, it fails if FSM is closed
+func (f *FSM[K, V]) begin() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+
+	f.inflight.Add(1)
+
+	return nil
+}
+
+// flush flushes the storage if it implements Flusher
+func flush(storage any) error {
+	if flusher, ok := storage.(Flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// closeStorage closes the storage if it implements io.Closer
+func closeStorage(storage any) error {
+	if closer, ok := storage.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// ping pings the storage if it implements Pinger
+func ping(ctx context.Context, storage any) error {
+	if pinger, ok := storage.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+// warm warms the storage if it implements Warmer
+func warm(ctx context.Context, storage any, userIDs []int64) error {
+	if warmer, ok := storage.(Warmer); ok {
+		return warmer.Warm(ctx, userIDs)
 	}
 
 	return nil
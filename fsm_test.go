@@ -0,0 +1,702 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+// noop is a callback doing nothing
+func noop(context.Context, ...any) error { return nil }
+
+// mustState returns the user's current state or fails the test
+func mustState[K comparable, V any](t *testing.T, f *FSM[K, V], userID int64) StateID {
+	t.Helper()
+
+	stateID, err := f.userStates.Get(userID)
+	if err != nil {
+		t.Fatalf("failed to get user state: %v", err)
+	}
+
+	return stateID
+}
+
+func TestChainedTransitionFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("a", map[StateID]Callback{
+		"b": func(ctx context.Context, args ...any) error {
+			return f.Transition(ctx, 1, "c")
+		},
+		"c": func(context.Context, ...any) error { return errTest },
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(ctx, 1, "b")
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected chained error, got %v", err)
+	}
+	if errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected state a, got %s", got)
+	}
+}
+
+func TestChainedTransitionFailureRollsBackCommittedData(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("a", map[StateID]Callback{
+		"b": func(ctx context.Context, args ...any) error {
+			return f.CommitAndTransition(ctx, 1, "c", map[string]int{"inner": 1})
+		},
+		"c": func(context.Context, ...any) error { return errTest },
+	})
+	if err := f.Set(1, "outer", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.CommitAndTransition(ctx, 1, "b", map[string]int{"outer": 2})
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected chained error, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected state a, got %s", got)
+	}
+
+	data, err := f.all(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data["outer"] != 1 {
+		t.Fatalf("expected data to be rolled back, got %v", data)
+	}
+}
+
+func TestChainedTransitionSuccessKeepsMovedState(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("a", map[StateID]Callback{
+		"b": func(ctx context.Context, args ...any) error {
+			if err := f.Transition(ctx, 1, "c"); err != nil {
+				return err
+			}
+
+			return errTest
+		},
+		"c": noop,
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(ctx, 1, "b")
+	if !errors.Is(err, errTest) || errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected only the callback error, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "c" {
+		t.Fatalf("expected state c, got %s", got)
+	}
+}
+
+func TestCallbackFailureRollsBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage UserStateStorage
+	}{
+		{name: "versioned", storage: initialUserStateStorage()},
+		{name: "unversioned", storage: NewObservableUserStateStorage(unversioned{initialUserStateStorage()}, func(StorageEvent) {})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("a", map[StateID]Callback{
+				"b": func(context.Context, ...any) error { return errTest },
+			}, WithUserStateStorage[string, int](tt.storage))
+			if err := f.Init(1); err != nil {
+				t.Fatal(err)
+			}
+
+			err := f.Transition(context.Background(), 1, "b")
+			if !errors.Is(err, errTest) {
+				t.Fatalf("expected callback error, got %v", err)
+			}
+			if got := mustState(t, f, 1); got != "a" {
+				t.Fatalf("expected state a, got %s", got)
+			}
+		})
+	}
+}
+
+// unversioned hides the versioning of the wrapped storage
+type unversioned struct {
+	UserStateStorage
+}
+
+func TestStickyCommitWritesData(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			calls++
+			return nil
+		},
+	}, WithStickyStates[string, int]("b"))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := f.CommitAndTransition(ctx, 1, "b", map[string]int{"k": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := f.Get(1, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 2 {
+		t.Fatalf("expected 2, got %d", v)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 callback call, got %d", calls)
+	}
+}
+
+func TestStay(t *testing.T) {
+	ctx := context.Background()
+
+	var hookTo StateID
+	var hookErr error
+	observed := 0
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error { return ErrStay },
+	},
+		WithAfterTransition[string, int](func(_ context.Context, _ int64, _, to StateID, err error) {
+			hookTo, hookErr = to, err
+		}),
+		WithTransitionObserver[string, int](func(context.Context, int64, StateID, StateID, ...any) error {
+			observed++
+			return nil
+		}),
+	)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.CommitAndTransition(ctx, 1, "b", map[string]int{"k": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected state a, got %s", got)
+	}
+	data, err := f.all(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("expected committed data to be rolled back, got %v", data)
+	}
+	if hookTo != "a" || hookErr != nil {
+		t.Fatalf("expected after hook with to a and no error, got %s, %v", hookTo, hookErr)
+	}
+	if observed != 0 {
+		t.Fatalf("expected no observer calls, got %d", observed)
+	}
+	if m := f.Metrics(); m.Transitions != 0 || m.Failures != 0 {
+		t.Fatalf("expected no counted transitions, got %+v", m)
+	}
+}
+
+func TestRefireStay(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{
+		"a": func(context.Context, ...any) error { return ErrStay },
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Refire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunInitialOnSeed(t *testing.T) {
+	calls := 0
+	var from, to StateID
+	f := New[string, int]("a", map[StateID]Callback{
+		"a": func(context.Context, ...any) error {
+			calls++
+			return nil
+		},
+	},
+		WithRunInitialOnSeed[string, int](),
+		WithTransitionObserver[string, int](func(_ context.Context, _ int64, f, t StateID, _ ...any) error {
+			from, to = f, t
+			return nil
+		}),
+	)
+
+	stateID, err := f.Current(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stateID != "a" || calls != 1 {
+		t.Fatalf("expected state a and 1 callback call, got %s and %d", stateID, calls)
+	}
+	if from != "a" || to != "a" {
+		t.Fatalf("expected observer from a to a, got %s to %s", from, to)
+	}
+	if seq, _ := f.Seq(1); seq != 1 {
+		t.Fatalf("expected seq 1, got %d", seq)
+	}
+	if n, _ := f.VisitCount(1, "a"); n != 1 {
+		t.Fatalf("expected 1 visit, got %d", n)
+	}
+}
+
+func TestRunInitialOnSeedChecksArgs(t *testing.T) {
+	f := New[string, int]("a", nil, WithRunInitialOnSeed[string, int]())
+	f.AddCallbackTyped("a", []reflect.Type{reflect.TypeOf(0)}, noop)
+
+	if _, err := f.Current(1); !errors.Is(err, ErrArgMismatch) {
+		t.Fatalf("expected ErrArgMismatch, got %v", err)
+	}
+}
+
+func TestRunInitialOnSeedStay(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{
+		"a": func(context.Context, ...any) error { return ErrStay },
+	}, WithRunInitialOnSeed[string, int]())
+
+	if _, err := f.Current(1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunInitialOnSeedClosed(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"a": noop}, WithRunInitialOnSeed[string, int]())
+	if err := f.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Current(1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWaitReadyInterval(t *testing.T) {
+	f := New[string, int]("a", nil)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantErr  error
+	}{
+		{name: "zero", interval: 0, wantErr: ErrInvalidInput},
+		{name: "negative", interval: -time.Second, wantErr: ErrInvalidInput},
+		{name: "positive", interval: time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.WaitReady(context.Background(), tt.interval)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// vanishedUsers is a user state storage listing a user without a state, like one removed after listing
+type vanishedUsers struct {
+	*userStateStorage
+}
+
+func (s vanishedUsers) Users() ([]int64, error) {
+	userIDs, err := s.userStateStorage.Users()
+	return append(userIDs, 42), err
+}
+
+func TestRenameStateSkipsVanishedUsers(t *testing.T) {
+	f := New[string, int]("a", nil, WithUserStateStorage[string, int](vanishedUsers{initialUserStateStorage()}))
+	for _, userID := range []int64{1, 2} {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Transition(context.Background(), 2, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := f.RenameState(context.Background(), "a", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 renamed user, got %d", n)
+	}
+	if got := mustState(t, f, 1); got != "c" {
+		t.Fatalf("expected state c, got %s", got)
+	}
+	if got := mustState(t, f, 2); got != "b" {
+		t.Fatalf("expected state b, got %s", got)
+	}
+}
+
+func TestAddCallbacksConcurrentWithTransitions(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("a", map[StateID]Callback{"a": noop, "b": noop})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 50; i++ {
+		if err := f.Init(int64(i)); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(2)
+		go func(userID int64) {
+			defer wg.Done()
+			for _, stateID := range []StateID{"b", "a"} {
+				if err := f.Transition(ctx, userID, stateID); err != nil {
+					errs <- err
+				}
+			}
+		}(int64(i))
+		go func() {
+			defer wg.Done()
+			f.AddCallbacks(map[StateID]Callback{"a": noop, "b": noop})
+			f.AddCallbackTyped("c", nil, noop)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestDataValidator(t *testing.T) {
+	f := New[string, int]("a", nil)
+	f.AddDataValidator("age", func(age int) error {
+		if age < 18 || age > 100 {
+			return errTest
+		}
+		return nil
+	})
+
+	if err := f.Set(1, "age", 30); err != nil {
+		t.Fatalf("expected a valid value to be stored, got %v", err)
+	}
+
+	err := f.Set(1, "age", 12)
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected validator error, got %v", err)
+	}
+	if age, err := f.Get(1, "age"); err != nil || age != 30 {
+		t.Fatalf("expected the invalid value not to be stored, got %d, %v", age, err)
+	}
+
+	if err := f.Set(1, "score", -1); err != nil {
+		t.Fatalf("expected an unvalidated key to be stored freely, got %v", err)
+	}
+}
+
+func TestCollectData(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"b": noop})
+	for userID := int64(1); userID <= 3; userID++ {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, userID := range []int64{1, 2} {
+		if err := f.Transition(context.Background(), userID, "b"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, userID := range []int64{1, 3} {
+		if err := f.Set(userID, "score", int(userID)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := f.CollectData("b", "score")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]int{1: 10}; !reflect.DeepEqual(data, want) {
+		t.Fatalf("expected %v, got %v", want, data)
+	}
+}
+
+func TestNilCallback(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"b": nil})
+	f.AddCallback("c", noop)
+	f.AddCallback("c", nil)
+	f.AddCallbacks(map[StateID]Callback{"d": nil})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stateID := range []StateID{"b", "c", "d"} {
+		if err := f.Transition(context.Background(), 1, stateID); err != nil {
+			t.Fatalf("expected transition to %s without a callback, got %v", stateID, err)
+		}
+		if info := f.StateInfo(stateID); info.HasCallback {
+			t.Fatalf("expected %s to have no callback", stateID)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"b": noop})
+	for userID := int64(1); userID <= 3; userID++ {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Transition(context.Background(), 2, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int64]StateID)
+	err := f.Range(func(userID int64, stateID StateID) bool {
+		seen[userID] = stateID
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]StateID{1: "a", 2: "b", 3: "a"}; !reflect.DeepEqual(seen, want) {
+		t.Fatalf("expected %v, got %v", want, seen)
+	}
+
+	var calls int
+	err = f.Range(func(int64, StateID) bool {
+		calls++
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected Range to stop after the first user, got %d calls", calls)
+	}
+}
+
+func TestResetAll(t *testing.T) {
+	ctx := context.Background()
+
+	var calls int
+	counting := func(context.Context, ...any) error {
+		calls++
+		return nil
+	}
+	states := map[int64]StateID{1: "a", 2: "b", 3: "c"}
+
+	tests := []struct {
+		name   string
+		filter func(StateID) bool
+		reset  int
+		want   map[int64]StateID
+	}{
+		{name: "all", reset: 3, want: map[int64]StateID{1: "a", 2: "a", 3: "a"}},
+		{
+			name:   "filtered",
+			filter: func(stateID StateID) bool { return stateID == "b" },
+			reset:  1,
+			want:   map[int64]StateID{1: "a", 2: "a", 3: "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("a", map[StateID]Callback{"a": counting, "b": noop, "c": noop})
+			for userID, stateID := range states {
+				if err := f.Init(userID); err != nil {
+					t.Fatal(err)
+				}
+				if stateID != "a" {
+					if err := f.Transition(ctx, userID, stateID); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			calls = 0
+
+			n, err := f.ResetAll(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.reset {
+				t.Fatalf("expected %d reset users, got %d", tt.reset, n)
+			}
+			for userID, want := range tt.want {
+				if got := mustState(t, f, userID); got != want {
+					t.Fatalf("expected user %d in %s, got %s", userID, want, got)
+				}
+			}
+			if calls != 0 {
+				t.Fatalf("expected no callbacks, got %d calls", calls)
+			}
+		})
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	for name, storage := range dataStorages {
+		t.Run(name, func(t *testing.T) {
+			f := New[string, int]("a", nil, WithDataStorage[string, int](storage()))
+			if err := f.Set(1, "code", 42); err != nil {
+				t.Fatal(err)
+			}
+
+			v, ok, err := f.GetAndDelete(1, "code")
+			if err != nil || !ok || v != 42 {
+				t.Fatalf("expected 42, got %d, %t, %v", v, ok, err)
+			}
+
+			if _, ok, err := f.GetAndDelete(1, "code"); err != nil || ok {
+				t.Fatalf("expected the value to be returned once, got %t, %v", ok, err)
+			}
+			if _, ok, err := f.GetAndDelete(2, "missing"); err != nil || ok {
+				t.Fatalf("expected no value for an absent key, got %t, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	ctx := context.Background()
+
+	newFSM := func() *FSM[string, int] {
+		f := New[string, int]("a", map[StateID]Callback{"limited": noop},
+			WithStateRateLimit[string, int]("limited", 1, 0),
+			WithAuthorizedStates[string, int](map[StateID]Authorizer{
+				"admin": func(int64) (bool, error) { return false, nil },
+			}),
+			WithBeforeTransition[string, int](func(_ context.Context, _ int64, _, to StateID) error {
+				if to == "vetoed" {
+					return errTest
+				}
+				return nil
+			}),
+		)
+		if err := f.Init(1); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		stateID StateID
+		close   bool
+		want    error
+	}{
+		{name: "allowed", userID: 1, stateID: "b"},
+		{name: "closed", userID: 1, stateID: "b", close: true, want: ErrClosed},
+		{name: "unknown user", userID: 2, stateID: "b", want: ErrNoUserState},
+		{name: "unauthorized", userID: 1, stateID: "admin", want: ErrUnauthorized},
+		{name: "vetoed", userID: 1, stateID: "vetoed", want: ErrVetoed},
+		{name: "rate limited", userID: 1, stateID: "limited", want: ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFSM()
+			if tt.close {
+				if err := f.Close(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ok, err := f.CanTransition(ctx, tt.userID, tt.stateID)
+			if tt.want == nil {
+				if !ok || err != nil {
+					t.Fatalf("expected the transition to be allowed, got %t, %v", ok, err)
+				}
+			} else if ok || !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %t, %v", tt.want, ok, err)
+			}
+
+			if got := mustState(t, f, 1); got != "a" {
+				t.Fatalf("expected CanTransition not to transition, got %s", got)
+			}
+		})
+	}
+}
+
+func TestStatesDoesNotWrite(t *testing.T) {
+	calls := countingHook{}
+	states := NewObservableUserStateStorage(initialUserStateStorage(), calls.hook)
+	f := New[string, int]("a", nil, WithUserStateStorage[string, int](states))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	writes := calls["Set"] + calls["SetIfVersion"]
+
+	got, err := f.States([]int64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]StateID{1: "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if n := calls["Set"] + calls["SetIfVersion"] - writes; n != 0 {
+		t.Fatalf("expected no writes for unknown users, got %d", n)
+	}
+}
+
+func TestOnVisit(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"b": noop})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Transition(context.Background(), 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	var hits atomic.Int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nth, err := f.OnVisit(1, "b", 3)
+			if err != nil {
+				t.Error(err)
+			}
+			if nth {
+				hits.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h := hits.Load(); h != 1 {
+		t.Fatalf("expected OnVisit to report the 3rd visit once, got %d", h)
+	}
+	if visits, _ := f.VisitCount(1, "b"); visits != n+1 {
+		t.Fatalf("expected %d visits, got %d", n+1, visits)
+	}
+}
@@ -0,0 +1,38 @@
+package fsm
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetOrSetRunsFnOnce(t *testing.T) {
+	f := New[string, int]("a", nil)
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	values := make([]int, 50)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := f.GetOrSet(1, "k", func() (int, error) {
+				return int(calls.Add(1)), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			values[i] = v
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected fn to run once, ran %d times", n)
+	}
+	for _, v := range values {
+		if v != 1 {
+			t.Fatalf("expected every caller to get 1, got %v", values)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package fsm
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+)
+
+// defaultIdempotencyWindow is a default number of remembered idempotency keys per user
+const defaultIdempotencyWindow = 100
+
+// maxIdempotencyUsers is a number of users whose idempotency keys are remembered,
+// keys of the user who used TransitionOnce least recently are forgotten first
+const maxIdempotencyUsers = 10000
+
+// processedKeys are the windows of processed idempotency keys of the latest users
+type processedKeys struct {
+	windows map[int64]*list.Element
+	order   *list.List
+}
+
+// newProcessedKeys creates empty processed keys
+func newProcessedKeys() *processedKeys {
+	return &processedKeys{
+		windows: make(map[int64]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// window returns the user's window, creating it and forgetting the least recent user if there are too many
+func (p *processedKeys) window(userID int64) *keyWindow {
+	if el, ok := p.windows[userID]; ok {
+		p.order.MoveToFront(el)
+		return el.Value.(*keyWindow)
+	}
+
+	w := &keyWindow{userID: userID, set: make(map[string]struct{})}
+	p.windows[userID] = p.order.PushFront(w)
+
+	if p.order.Len() > maxIdempotencyUsers {
+		p.forget(p.order.Back().Value.(*keyWindow).userID)
+	}
+
+	return w
+}
+
+// forget drops the user's window
+func (p *processedKeys) forget(userID int64) {
+	if el, ok := p.windows[userID]; ok {
+		p.order.Remove(el)
+		delete(p.windows, userID)
+	}
+}
+
+// keyWindow is a bounded set of the latest processed idempotency keys
+type keyWindow struct {
+	userID int64
+	keys   []string
+	set    map[string]struct{}
+}
+
+// reserve adds the key to the window, it returns false if the key is already there
+func (w *keyWindow) reserve(key string, size int) bool {
+	if _, ok := w.set[key]; ok {
+		return false
+	}
+
+	if len(w.keys) >= size {
+		delete(w.set, w.keys[0])
+		w.keys = w.keys[1:]
+	}
+
+	w.keys = append(w.keys, key)
+	w.set[key] = struct{}{}
+
+	return true
+}
+
+// release removes the key from the window
+func (w *keyWindow) release(key string) {
+	delete(w.set, key)
+
+	for i, k := range w.keys {
+		if k == key {
+			w.keys = append(w.keys[:i], w.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// TransitionOnce transitions the user to a new state once per idempotency key.
+// Repeated calls with an already processed key return ErrDuplicateTransition.
+// A failed transition doesn't mark the key as processed. Keys are remembered for the 10000 users
+// who used TransitionOnce most recently
+func (f *FSM[K, V]) TransitionOnce(ctx context.Context, userID int64, stateID StateID, idempotencyKey string, args ...any) error {
+	f.mu.Lock()
+	w := f.processed.window(userID)
+	reserved := w.reserve(idempotencyKey, f.idempotencyWindow)
+	f.mu.Unlock()
+
+	if !reserved {
+		return wrapError("transition", userID, stateID, fmt.Errorf("%w: key: %s", ErrDuplicateTransition, idempotencyKey))
+	}
+
+	err := f.Transition(ctx, userID, stateID, args...)
+	if err != nil {
+		f.mu.Lock()
+		w.release(idempotencyKey)
+		f.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
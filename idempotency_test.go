@@ -0,0 +1,185 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransitionOnce(t *testing.T) {
+	ctx := context.Background()
+
+	var calls int
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			calls++
+			return nil
+		},
+	})
+	for _, userID := range []int64{1, 2} {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.TransitionOnce(ctx, 1, "b", "key"); err != nil {
+		t.Fatal(err)
+	}
+	err := f.TransitionOnce(ctx, 1, "b", "key")
+	if !errors.Is(err, ErrDuplicateTransition) {
+		t.Fatalf("expected ErrDuplicateTransition, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 callback call, got %d", calls)
+	}
+
+	if err := f.TransitionOnce(ctx, 2, "b", "key"); err != nil {
+		t.Fatalf("expected keys to be per user, got %v", err)
+	}
+}
+
+func TestTransitionOnceFailureReleasesKey(t *testing.T) {
+	ctx := context.Background()
+
+	fail := true
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error {
+			if fail {
+				return errTest
+			}
+			return nil
+		},
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.TransitionOnce(ctx, 1, "b", "key"); !errors.Is(err, errTest) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	fail = false
+	if err := f.TransitionOnce(ctx, 1, "b", "key"); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+}
+
+func TestTransitionOnceWindow(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("a", map[StateID]Callback{"b": noop}, WithIdempotencyWindow[string, int](2))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"1", "2", "3"} {
+		if err := f.TransitionOnce(ctx, 1, "b", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.TransitionOnce(ctx, 1, "b", "1"); err != nil {
+		t.Fatalf("expected the oldest key to be forgotten, got %v", err)
+	}
+	if err := f.TransitionOnce(ctx, 1, "b", "3"); !errors.Is(err, ErrDuplicateTransition) {
+		t.Fatalf("expected ErrDuplicateTransition, got %v", err)
+	}
+}
+
+func TestProcessedKeysForgetLeastRecentUser(t *testing.T) {
+	p := newProcessedKeys()
+
+	for userID := int64(0); userID < maxIdempotencyUsers; userID++ {
+		p.window(userID).reserve("key", 1)
+	}
+	p.window(0)
+	p.window(maxIdempotencyUsers)
+
+	if len(p.windows) != maxIdempotencyUsers {
+		t.Fatalf("expected %d windows, got %d", maxIdempotencyUsers, len(p.windows))
+	}
+	if _, ok := p.windows[1]; ok {
+		t.Fatal("expected the least recent user to be forgotten")
+	}
+	if !p.window(0).reserve("other", 1) || p.window(0).reserve("other", 1) {
+		t.Fatal("expected the recently used window to be kept")
+	}
+}
+
+func TestRateLimiterSweepsFullBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newRateLimiter(1, 1)
+
+	for userID := int64(0); userID < minSweepSize; userID++ {
+		r.allow(userID, now)
+	}
+	r.allow(minSweepSize, now.Add(time.Second))
+
+	if len(r.buckets) != 1 {
+		t.Fatalf("expected refilled buckets to be swept, got %d buckets", len(r.buckets))
+	}
+}
+
+func TestExpiredProposalsSwept(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	f := New[string, int]("a", nil, WithClock[string, int](clock))
+
+	for userID := int64(0); userID < minSweepSize; userID++ {
+		if _, err := f.ProposeTransition(userID, "b"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.now = clock.now.Add(defaultProposalTTL)
+	if _, err := f.ProposeTransition(minSweepSize, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.proposals) != 1 {
+		t.Fatalf("expected expired proposals to be swept, got %d proposals", len(f.proposals))
+	}
+}
+
+func TestReplaceAndClearForgetUser(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		forget func(f *FSM[string, int]) error
+	}{
+		{name: "replace user", forget: func(f *FSM[string, int]) error { return f.ReplaceUser(1, "a", nil) }},
+		{name: "clear data", forget: func(f *FSM[string, int]) error { return f.ClearData(1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("a", map[StateID]Callback{"b": noop}, WithStateRateLimit[string, int]("b", 1, 1))
+			if err := f.Init(1); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.TransitionOnce(ctx, 1, "b", "key"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.ProposeTransition(1, "b"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.forget(f); err != nil {
+				t.Fatal(err)
+			}
+
+			if seq, _ := f.Seq(1); seq != 0 {
+				t.Fatalf("expected seq to start over, got %d", seq)
+			}
+			if visits, _ := f.VisitCount(1, "b"); visits != 0 {
+				t.Fatalf("expected visits to start over, got %d", visits)
+			}
+			if len(f.proposals) != 0 {
+				t.Fatal("expected the proposal to be forgotten")
+			}
+			if err := f.TransitionOnce(ctx, 1, "b", "key"); err != nil {
+				t.Fatalf("expected the key and rate limit to be forgotten, got %v", err)
+			}
+		})
+	}
+}
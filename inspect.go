@@ -0,0 +1,144 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UserSnapshot is everything FSM knows about a user
+type UserSnapshot[K comparable, V any] struct {
+	UserID int64 `json:"user_id"`
+	// State is the current state of the user, empty if the user has no state
+	State StateID `json:"state"`
+	// Data is the user's data, nil if data storage doesn't implement DataEnumerator
+	Data map[K]V `json:"data"`
+	// Lists is the user's lists, nil if data storage can't keep lists
+	Lists map[K][]V `json:"lists,omitempty"`
+	// Locale is the locale set for the user, empty if there is none
+	Locale string `json:"locale,omitempty"`
+}
+
+// Inspect returns a snapshot of the user's state and data for debugging.
+// Unlike Current, it doesn't seed the initial state for unknown users
+func (f *FSM[K, V]) Inspect(userID int64) (UserSnapshot[K, V], error) {
+	snapshot := UserSnapshot[K, V]{UserID: userID}
+
+	ok, err := f.userStates.Exists(userID)
+	if err != nil {
+		return snapshot, wrapError("inspect", userID, "", fmt.Errorf("failed to check user state: %w", err))
+	}
+	if ok {
+		snapshot.State, err = f.userStates.Get(userID)
+		if err != nil {
+			return snapshot, wrapError("inspect", userID, "", fmt.Errorf("failed to get user state: %w", err))
+		}
+	}
+
+	snapshot.Data, err = f.all(userID)
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return snapshot, wrapError("inspect", userID, "", err)
+	}
+
+	snapshot.Lists, err = f.lists(userID)
+	if err != nil {
+		return snapshot, wrapError("inspect", userID, "", err)
+	}
+
+	snapshot.Locale, _, err = f.locale(userID)
+	if err != nil {
+		return snapshot, wrapError("inspect", userID, "", err)
+	}
+
+	return snapshot, nil
+}
+
+// SnapshotUser returns a snapshot of the user's state and data taken under the user's lock,
+// so it's consistent with concurrent transitions and data writes. Data storage must implement DataEnumerator
+func (f *FSM[K, V]) SnapshotUser(userID int64) (UserSnapshot[K, V], error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	snapshot := UserSnapshot[K, V]{UserID: userID}
+
+	ok, err := f.userStates.Exists(userID)
+	if err != nil {
+		return snapshot, wrapError("snapshot user", userID, "", fmt.Errorf("failed to check user state: %w", err))
+	}
+	if ok {
+		snapshot.State, err = f.userStates.Get(userID)
+		if err != nil {
+			return snapshot, wrapError("snapshot user", userID, "", fmt.Errorf("failed to get user state: %w", err))
+		}
+	}
+
+	snapshot.Data, err = f.all(userID)
+	if err != nil {
+		return snapshot, wrapError("snapshot user", userID, "", err)
+	}
+
+	snapshot.Lists, err = f.lists(userID)
+	if err != nil {
+		return snapshot, wrapError("snapshot user", userID, "", err)
+	}
+
+	snapshot.Locale, _, err = f.locale(userID)
+	if err != nil {
+		return snapshot, wrapError("snapshot user", userID, "", err)
+	}
+
+	return snapshot, nil
+}
+
+// all returns all user's data from data storage
+func (f *FSM[K, V]) all(userID int64) (map[K]V, error) {
+	enumerator, ok := f.storage.(DataEnumerator[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't enumerate user data", ErrNotSupported)
+	}
+
+	data, err := enumerator.All(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all user data: %w", err)
+	}
+
+	return data, nil
+}
+
+// lists returns all user's lists from data storage, nil if data storage can't keep lists
+func (f *FSM[K, V]) lists(userID int64) (map[K][]V, error) {
+	lister, ok := f.storage.(DataLister[K, V])
+	if !ok {
+		return nil, nil
+	}
+
+	lists, err := lister.Lists(userID)
+	if errors.Is(err, ErrNotSupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user lists: %w", err)
+	}
+
+	return lists, nil
+}
+
+// setLists replaces the user's lists in data storage, data storage must implement DataLister unless lists are empty
+func (f *FSM[K, V]) setLists(userID int64, lists map[K][]V) error {
+	if len(lists) == 0 {
+		return nil
+	}
+
+	lister, ok := f.storage.(DataLister[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	for key, list := range lists {
+		err := lister.SetList(userID, key, list)
+		if err != nil {
+			return fmt.Errorf("failed to set user list: %w", err)
+		}
+	}
+
+	return nil
+}
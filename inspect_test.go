@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{"b": noop})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Transition(context.Background(), 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(1, "age", 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Append(1, "scores", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetLocale(1, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := f.Inspect(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := UserSnapshot[string, int]{
+		UserID: 1,
+		State:  "b",
+		Data:   map[string]int{"age": 30},
+		Lists:  map[string][]int{"scores": {1}},
+		Locale: "en",
+	}
+	if !reflect.DeepEqual(snapshot, want) {
+		t.Fatalf("expected snapshot %+v, got %+v", want, snapshot)
+	}
+}
+
+func TestInspectUnknownUser(t *testing.T) {
+	f := New[string, int]("a", nil)
+
+	snapshot, err := f.Inspect(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.State != "" || len(snapshot.Data) != 0 {
+		t.Fatalf("expected an empty snapshot, got %+v", snapshot)
+	}
+
+	ok, err := f.userStates.Exists(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected Inspect not to seed the user")
+	}
+}
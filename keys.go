@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// AllKeys returns the data and list keys of every known user, users without data are omitted.
+// It reads data of all users, so it's O(users). User state storage must implement UserStateEnumerator
+// and data storage must implement DataEnumerator, users without a state aren't listed
+func (f *FSM[K, V]) AllKeys() (map[int64][]K, error) {
+	userIDs, err := f.users()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[int64][]K)
+	for _, userID := range userIDs {
+		data, err := f.all(userID)
+		if err != nil {
+			return nil, wrapError("all keys", userID, "", err)
+		}
+
+		for key := range data {
+			keys[userID] = append(keys[userID], key)
+		}
+
+		lists, err := f.lists(userID)
+		if err != nil {
+			return nil, wrapError("all keys", userID, "", err)
+		}
+
+		for key := range lists {
+			if _, ok := data[key]; !ok {
+				keys[userID] = append(keys[userID], key)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// RenameKey moves the value of the from key to the to key for every known user, overwriting the to key.
+// It returns the number of users whose key was moved. User state storage must implement
+// UserStateEnumerator and data storage must implement DataLookuper
+func (f *FSM[K, V]) RenameKey(ctx context.Context, from, to K) (int, error) {
+	userIDs, err := f.users()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, userID := range userIDs {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+
+		ok, err := f.renameKey(userID, from, to)
+		if err != nil {
+			return n, wrapError("rename key", userID, "", err)
+		}
+		if ok {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// renameKey moves the user's value of the from key to the to key under the user's lock
+func (f *FSM[K, V]) renameKey(userID int64, from, to K) (bool, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	v, ok, err := f.lookup(userID, from)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	err = f.set(userID, to, v)
+	if err != nil {
+		return false, err
+	}
+
+	err = f.storage.Delete(userID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user data: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestAllKeysAndRenameKey(t *testing.T) {
+	f := New[string, int]("a", nil)
+	for userID := int64(1); userID <= 3; userID++ {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Set(userID, "name", int(userID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Set(1, "age", 30); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := f.AllKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userKeys := range keys {
+		slices.Sort(userKeys)
+	}
+	want := map[int64][]string{1: {"age", "name"}, 2: {"name"}, 3: {"name"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %v, got %v", want, keys)
+	}
+
+	n, err := f.RenameKey(context.Background(), "name", "title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 users to be touched, got %d", n)
+	}
+	for userID := int64(1); userID <= 3; userID++ {
+		if v, err := f.Get(userID, "title"); err != nil || v != int(userID) {
+			t.Fatalf("expected user %d title %d, got %d, %v", userID, userID, v, err)
+		}
+		if _, ok, err := f.lookup(userID, "name"); err != nil || ok {
+			t.Fatalf("expected user %d name to be moved, got %t, %v", userID, ok, err)
+		}
+	}
+}
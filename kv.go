@@ -0,0 +1,343 @@
+package fsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// KVStore is an interface for a generic key-value store, KVUserStateStorage and KVDataStorage
+// build FSM storages on top of it
+type KVStore interface {
+	// Get returns the value of the key, false if there is no such key
+	Get(key []byte) ([]byte, bool, error)
+	Set(key, value []byte) error
+	Delete(key []byte) error
+	// Scan returns all keys starting with the prefix
+	Scan(prefix []byte) ([][]byte, error)
+}
+
+// KVUserStateStorage is a user state storage keeping states in a KVStore under "state/<userID>" keys
+type KVUserStateStorage struct {
+	store KVStore
+}
+
+// NewKVUserStateStorage creates a user state storage on top of the store
+func NewKVUserStateStorage(store KVStore) *KVUserStateStorage {
+	return &KVUserStateStorage{store: store}
+}
+
+// Set sets user's state to the store
+func (s *KVUserStateStorage) Set(userID int64, stateID StateID) error {
+	return s.store.Set(stateKey(userID), []byte(stateID))
+}
+
+// Exists checks whether user's state exists in the store
+func (s *KVUserStateStorage) Exists(userID int64) (bool, error) {
+	_, ok, err := s.store.Get(stateKey(userID))
+
+	return ok, err
+}
+
+// Get gets user's state from the store
+func (s *KVUserStateStorage) Get(userID int64) (StateID, error) {
+	value, ok, err := s.store.Get(stateKey(userID))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: userID: %d", ErrNoUserState, userID)
+	}
+
+	return StateID(value), nil
+}
+
+// Users returns all users with a state in the store
+func (s *KVUserStateStorage) Users() ([]int64, error) {
+	prefix := []byte("state/")
+
+	keys, err := s.store.Scan(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		userID, err := strconv.ParseInt(string(bytes.TrimPrefix(key, prefix)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse user state key %q: %w", key, err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// stateKey returns the store key of user's state
+func stateKey(userID int64) []byte {
+	return []byte("state/" + strconv.FormatInt(userID, 10))
+}
+
+// KVDataStorage is a data storage keeping JSON encoded values in a KVStore
+// under "data/<userID>/<JSON encoded key>" keys and lists under "list/<userID>/<JSON encoded key>" keys
+type KVDataStorage[K comparable, V any] struct {
+	store KVStore
+}
+
+// NewKVDataStorage creates a data storage on top of the store
+func NewKVDataStorage[K comparable, V any](store KVStore) *KVDataStorage[K, V] {
+	return &KVDataStorage[K, V]{store: store}
+}
+
+// Set sets user's data to the store
+func (s *KVDataStorage[K, V]) Set(userID int64, key K, value V) error {
+	k, err := s.key(userID, key)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return s.store.Set(k, v)
+}
+
+// Get gets user's data from the store. Like the in-memory storage, it returns ErrNoUserData
+// only for users without any data, a missing key of a user with data has the zero value
+func (s *KVDataStorage[K, V]) Get(userID int64, key K) (V, error) {
+	v, ok, err := s.Lookup(userID, key)
+	if err != nil || ok {
+		return v, err
+	}
+
+	n, err := s.Count(userID)
+	if err != nil {
+		return v, err
+	}
+	if n == 0 {
+		return v, fmt.Errorf("%w, userID:%d, comparable:%v", ErrNoUserData, userID, key)
+	}
+
+	return v, nil
+}
+
+// Delete deletes user's data from the store
+func (s *KVDataStorage[K, V]) Delete(userID int64, key K) error {
+	k, err := s.key(userID, key)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Delete(k)
+}
+
+// Lookup gets user's data from the store, it returns false if there is no such key
+func (s *KVDataStorage[K, V]) Lookup(userID int64, key K) (V, bool, error) {
+	var v V
+
+	k, err := s.key(userID, key)
+	if err != nil {
+		return v, false, err
+	}
+
+	value, ok, err := s.store.Get(k)
+	if err != nil || !ok {
+		return v, false, err
+	}
+
+	err = json.Unmarshal(value, &v)
+	if err != nil {
+		return v, false, fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+
+	return v, true, nil
+}
+
+// GetAndDelete gets user's data and deletes it from the store, it returns false if there is no such key.
+// The two steps aren't atomic in the store, FSM runs them under the user's lock
+func (s *KVDataStorage[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	v, ok, err := s.Lookup(userID, key)
+	if err != nil || !ok {
+		return v, ok, err
+	}
+
+	return v, true, s.Delete(userID, key)
+}
+
+// All returns all user's data from the store
+func (s *KVDataStorage[K, V]) All(userID int64) (map[K]V, error) {
+	prefix := s.prefix(userID)
+
+	keys, err := s.store.Scan(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[K]V, len(keys))
+	for _, k := range keys {
+		var key K
+		err = json.Unmarshal(bytes.TrimPrefix(k, prefix), &key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal key %q: %w", k, err)
+		}
+
+		value, ok, err := s.store.Get(k)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		var v V
+		err = json.Unmarshal(value, &v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal value: %w", err)
+		}
+		data[key] = v
+	}
+
+	return data, nil
+}
+
+// Count returns the number of user's keys in the store
+func (s *KVDataStorage[K, V]) Count(userID int64) (int, error) {
+	keys, err := s.store.Scan(s.prefix(userID))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Append appends a value to user's list in the store.
+// Reading and writing the list aren't atomic in the store, FSM runs them under the user's lock
+func (s *KVDataStorage[K, V]) Append(userID int64, key K, value V) error {
+	list, err := s.List(userID, key)
+	if err != nil {
+		return err
+	}
+
+	return s.SetList(userID, key, append(list, value))
+}
+
+// List returns user's list from the store, empty if there is no such list
+func (s *KVDataStorage[K, V]) List(userID int64, key K) ([]V, error) {
+	k, err := s.listKey(userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok, err := s.store.Get(k)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var list []V
+	err = json.Unmarshal(value, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal list: %w", err)
+	}
+
+	return list, nil
+}
+
+// Lists returns all user's lists from the store
+func (s *KVDataStorage[K, V]) Lists(userID int64) (map[K][]V, error) {
+	prefix := s.listPrefix(userID)
+
+	keys, err := s.store.Scan(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make(map[K][]V, len(keys))
+	for _, k := range keys {
+		var key K
+		err = json.Unmarshal(bytes.TrimPrefix(k, prefix), &key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal key %q: %w", k, err)
+		}
+
+		list, err := s.List(userID, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) > 0 {
+			lists[key] = list
+		}
+	}
+
+	return lists, nil
+}
+
+// SetList replaces user's list in the store, an empty list deletes it
+func (s *KVDataStorage[K, V]) SetList(userID int64, key K, list []V) error {
+	k, err := s.listKey(userID, key)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return s.store.Delete(k)
+	}
+
+	v, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal list: %w", err)
+	}
+
+	return s.store.Set(k, v)
+}
+
+// Clear deletes all user's data and lists from the store
+func (s *KVDataStorage[K, V]) Clear(userID int64) error {
+	for _, prefix := range [][]byte{s.prefix(userID), s.listPrefix(userID)} {
+		keys, err := s.store.Scan(prefix)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			err = s.store.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// prefix returns the store key prefix of user's data
+func (s *KVDataStorage[K, V]) prefix(userID int64) []byte {
+	return []byte("data/" + strconv.FormatInt(userID, 10) + "/")
+}
+
+// listPrefix returns the store key prefix of user's lists
+func (s *KVDataStorage[K, V]) listPrefix(userID int64) []byte {
+	return []byte("list/" + strconv.FormatInt(userID, 10) + "/")
+}
+
+// key returns the store key of user's data
+func (s *KVDataStorage[K, V]) key(userID int64, key K) ([]byte, error) {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	return append(s.prefix(userID), k...), nil
+}
+
+// listKey returns the store key of user's list
+func (s *KVDataStorage[K, V]) listKey(userID int64, key K) ([]byte, error) {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	return append(s.listPrefix(userID), k...), nil
+}
@@ -0,0 +1,186 @@
+package fsm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memKV is an in-memory KVStore
+type memKV struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemKV() *memKV {
+	return &memKV{data: make(map[string][]byte)}
+}
+
+func (m *memKV) Get(key []byte) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.data[string(key)]
+	return v, ok, nil
+}
+
+func (m *memKV) Set(key, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[string(key)] = bytes.Clone(value)
+	return nil
+}
+
+func (m *memKV) Delete(key []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data, string(key))
+	return nil
+}
+
+func (m *memKV) Scan(prefix []byte) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys [][]byte
+	for k := range m.data {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys, nil
+}
+
+func TestKVUserStateStorage(t *testing.T) {
+	s := NewKVUserStateStorage(newMemKV())
+
+	if _, err := s.Get(1); !errors.Is(err, ErrNoUserState) {
+		t.Fatalf("expected ErrNoUserState, got %v", err)
+	}
+	if ok, _ := s.Exists(1); ok {
+		t.Fatal("expected unknown user")
+	}
+
+	for _, userID := range []int64{1, 2} {
+		if err := s.Set(userID, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stateID, err := s.Get(1); err != nil || stateID != "a" {
+		t.Fatalf("expected state a, got %s, %v", stateID, err)
+	}
+
+	userIDs, err := s.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	if !reflect.DeepEqual(userIDs, []int64{1, 2}) {
+		t.Fatalf("expected users 1 and 2, got %v", userIDs)
+	}
+}
+
+// dataStorages are the data storages expected to behave the same
+var dataStorages = map[string]func() DataStorage[string, int]{
+	"memory": func() DataStorage[string, int] { return initialDataStorage[string, int]() },
+	"kv":     func() DataStorage[string, int] { return NewKVDataStorage[string, int](newMemKV()) },
+}
+
+func TestDataStorageGet(t *testing.T) {
+	for name, storage := range dataStorages {
+		t.Run(name, func(t *testing.T) {
+			s := storage()
+
+			if _, err := s.Get(1, "a"); !errors.Is(err, ErrNoUserData) {
+				t.Fatalf("expected ErrNoUserData for a user without data, got %v", err)
+			}
+
+			if err := s.Set(1, "a", 1); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := s.Get(1, "a"); err != nil || v != 1 {
+				t.Fatalf("expected 1, got %d, %v", v, err)
+			}
+			if v, err := s.Get(1, "b"); err != nil || v != 0 {
+				t.Fatalf("expected zero value for a missing key, got %d, %v", v, err)
+			}
+		})
+	}
+}
+
+func TestKVDataStorage(t *testing.T) {
+	s := NewKVDataStorage[string, int](newMemKV())
+	for key, value := range map[string]int{"a": 1, "b": 2} {
+		if err := s.Set(1, key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set(2, "a", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok, err := s.Lookup(1, "a"); err != nil || !ok || v != 1 {
+		t.Fatalf("expected 1, got %d, %t, %v", v, ok, err)
+	}
+	if _, ok, _ := s.Lookup(1, "c"); ok {
+		t.Fatal("expected missing key")
+	}
+
+	data, err := s.All(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("expected all user data, got %v", data)
+	}
+	if n, _ := s.Count(1); n != 2 {
+		t.Fatalf("expected 2 keys, got %d", n)
+	}
+
+	if v, ok, err := s.GetAndDelete(1, "a"); err != nil || !ok || v != 1 {
+		t.Fatalf("expected 1, got %d, %t, %v", v, ok, err)
+	}
+	if _, ok, _ := s.Lookup(1, "a"); ok {
+		t.Fatal("expected deleted key")
+	}
+
+	if err := s.Clear(1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Count(1); n != 0 {
+		t.Fatalf("expected cleared user, got %d keys", n)
+	}
+	if v, _ := s.Get(2, "a"); v != 3 {
+		t.Fatalf("expected other user's data to be kept, got %d", v)
+	}
+}
+
+func TestKVStoragesWithFSM(t *testing.T) {
+	store := newMemKV()
+	f := New[string, int]("a", nil,
+		WithUserStateStorage[string, int](NewKVUserStateStorage(store)),
+		WithDataStorage[string, int](NewKVDataStorage[string, int](store)),
+	)
+
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.CommitAndTransition(context.Background(), 1, "b", map[string]int{"k": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := f.Inspect(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.State != "b" || !reflect.DeepEqual(snapshot.Data, map[string]int{"k": 1}) {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
@@ -0,0 +1,125 @@
+package fsm
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// failingSet is an in-memory data storage failing Set of the "bad" key
+type failingSet struct {
+	*dataStorage[string, int]
+}
+
+func (s failingSet) Set(userID int64, key string, value int) error {
+	if key == "bad" {
+		return errTest
+	}
+	return s.dataStorage.Set(userID, key, value)
+}
+
+func TestLists(t *testing.T) {
+	for name, storage := range dataStorages {
+		t.Run(name, func(t *testing.T) {
+			f := New[string, int]("a", nil, WithDataStorage[string, int](storage()))
+			if err := f.Init(1); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 1; i <= 3; i++ {
+				if err := f.Append(1, "l", i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Set(1, "k", 7); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := f.List(1, "l")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(list, []int{1, 2, 3}) {
+				t.Fatalf("expected [1 2 3], got %v", list)
+			}
+			if n, _ := f.KeyCount(1); n != 1 {
+				t.Fatalf("expected 1 key, got %d", n)
+			}
+
+			keys, err := f.AllKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(keys[1])
+			if !reflect.DeepEqual(keys[1], []string{"k", "l"}) {
+				t.Fatalf("expected keys k and l, got %v", keys[1])
+			}
+
+			snapshot, err := f.SnapshotUser(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(snapshot.Lists, map[string][]int{"l": {1, 2, 3}}) {
+				t.Fatalf("expected snapshot lists, got %v", snapshot.Lists)
+			}
+
+			var buf bytes.Buffer
+			if err := f.Dump(context.Background(), &buf); err != nil {
+				t.Fatal(err)
+			}
+			loaded := New[string, int]("a", nil, WithDataStorage[string, int](storage()))
+			if err := loaded.Load(context.Background(), &buf); err != nil {
+				t.Fatal(err)
+			}
+			if list, _ := loaded.List(1, "l"); !reflect.DeepEqual(list, []int{1, 2, 3}) {
+				t.Fatalf("expected loaded list [1 2 3], got %v", list)
+			}
+
+			migrated := New[string, int]("a", nil, WithDataStorage[string, int](storage()))
+			if err := f.Migrate(context.Background(), migrated, nil); err != nil {
+				t.Fatal(err)
+			}
+			// migrating again must not duplicate the lists
+			if err := f.Migrate(context.Background(), migrated, nil); err != nil {
+				t.Fatal(err)
+			}
+			if list, _ := migrated.List(1, "l"); !reflect.DeepEqual(list, []int{1, 2, 3}) {
+				t.Fatalf("expected migrated list [1 2 3], got %v", list)
+			}
+
+			if err := f.ClearData(1); err != nil {
+				t.Fatal(err)
+			}
+			if list, _ := f.List(1, "l"); len(list) != 0 {
+				t.Fatalf("expected cleared list, got %v", list)
+			}
+		})
+	}
+}
+
+func TestReplaceUserRestoresLists(t *testing.T) {
+	f := New[string, int]("a", nil, WithDataStorage[string, int](failingSet{initialDataStorage[string, int]()}))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Append(1, "l", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.ReplaceUser(1, "b", map[string]int{"bad": 1}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	list, err := f.List(1, "l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []int{1}) {
+		t.Fatalf("expected restored list [1], got %v", list)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected state a, got %s", got)
+	}
+}
@@ -0,0 +1,93 @@
+package fsm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LocaleStorage is an interface for user locale storage
+type LocaleStorage interface {
+	SetLocale(userID int64, locale string) error
+	// Locale returns the user's locale, false if the user has none
+	Locale(userID int64) (string, bool, error)
+}
+
+// localeStorage is a type for default locale storage
+type localeStorage struct {
+	mu      sync.RWMutex
+	locales map[int64]string
+}
+
+// initialLocaleStorage creates in memory storage for user's locale
+func initialLocaleStorage() *localeStorage {
+	return &localeStorage{
+		locales: make(map[int64]string),
+	}
+}
+
+// SetLocale sets user's locale to locale storage
+func (l *localeStorage) SetLocale(userID int64, locale string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.locales[userID] = locale
+
+	return nil
+}
+
+// Locale gets user's locale from locale storage
+func (l *localeStorage) Locale(userID int64) (string, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	locale, ok := l.locales[userID]
+
+	return locale, ok, nil
+}
+
+// SetLocale sets the user's locale, callbacks can read it by Locale to pick strings
+func (f *FSM[K, V]) SetLocale(userID int64, locale string) error {
+	err := f.locales.SetLocale(userID, locale)
+	if err != nil {
+		return wrapError("set locale", userID, "", fmt.Errorf("failed to set user locale: %w", err))
+	}
+
+	return nil
+}
+
+// Locale returns the user's locale, the one set by WithDefaultLocale if the user has none
+func (f *FSM[K, V]) Locale(userID int64) (string, error) {
+	locale, ok, err := f.locale(userID)
+	if err != nil {
+		return "", wrapError("locale", userID, "", err)
+	}
+	if !ok {
+		return f.defaultLocale, nil
+	}
+
+	return locale, nil
+}
+
+// locale returns the locale set for the user without the default one
+func (f *FSM[K, V]) locale(userID int64) (string, bool, error) {
+	locale, ok, err := f.locales.Locale(userID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get user locale: %w", err)
+	}
+
+	return locale, ok, nil
+}
+
+// setLocale sets the user's locale, an empty locale is skipped
+func (f *FSM[K, V]) setLocale(userID int64, locale string) error {
+	if locale == "" {
+		return nil
+	}
+
+	err := f.locales.SetLocale(userID, locale)
+	if err != nil {
+		return fmt.Errorf("failed to set user locale: %w", err)
+	}
+
+	return nil
+}
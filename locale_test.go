@@ -0,0 +1,86 @@
+package fsm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option[string, int]
+		set    string
+		expect string
+	}{
+		{name: "set", set: "de", expect: "de"},
+		{name: "no locale", expect: ""},
+		{name: "default", opts: []Option[string, int]{WithDefaultLocale[string, int]("en")}, expect: "en"},
+		{name: "set over default", opts: []Option[string, int]{WithDefaultLocale[string, int]("en")}, set: "de", expect: "de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("a", nil, tt.opts...)
+			if tt.set != "" {
+				if err := f.SetLocale(1, tt.set); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			locale, err := f.Locale(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locale != tt.expect {
+				t.Fatalf("expected %q, got %q", tt.expect, locale)
+			}
+		})
+	}
+}
+
+func TestLocaleIsCarried(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("a", nil)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetLocale(1, "de"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := f.Inspect(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Locale != "de" {
+		t.Fatalf("expected snapshot locale de, got %q", snapshot.Locale)
+	}
+
+	migrated := New[string, int]("a", nil)
+	if err := f.Migrate(ctx, migrated, nil); err != nil {
+		t.Fatal(err)
+	}
+	if locale, _ := migrated.Locale(1); locale != "de" {
+		t.Fatalf("expected migrated locale de, got %q", locale)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Dump(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New[string, int]("a", nil)
+	if err := loaded.Load(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if locale, _ := loaded.Locale(1); locale != "de" {
+		t.Fatalf("expected loaded locale de, got %q", locale)
+	}
+
+	if err := f.ReplaceUser(1, "b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if locale, _ := f.Locale(1); locale != "de" {
+		t.Fatalf("expected replaced user to keep locale de, got %q", locale)
+	}
+}
@@ -0,0 +1,45 @@
+package fsm
+
+import "sync"
+
+// userLocks is a set of per-user mutexes, a mutex lives only while it is held or awaited,
+// so the set doesn't grow with the number of users and different users never block each other
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[int64]*userLock
+}
+
+// userLock is a user's mutex with a number of holders and waiters
+type userLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks the user's mutex and returns a function that unlocks it
+func (l *userLocks) lock(userID int64) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[int64]*userLock)
+	}
+
+	ul, ok := l.locks[userID]
+	if !ok {
+		ul = &userLock{}
+		l.locks[userID] = ul
+	}
+	ul.refs++
+	l.mu.Unlock()
+
+	ul.mu.Lock()
+
+	return func() {
+		ul.mu.Unlock()
+
+		l.mu.Lock()
+		ul.refs--
+		if ul.refs == 0 {
+			delete(l.locks, userID)
+		}
+		l.mu.Unlock()
+	}
+}
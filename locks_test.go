@@ -0,0 +1,69 @@
+package fsm
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockDoesNotBlockOtherUsers(t *testing.T) {
+	f := New[string, int]("a", nil)
+
+	done := make(chan error, 1)
+	go func() {
+		// users 1 and 65 shared a mutex when locks were sharded by userID%64
+		_, err := f.GetOrSet(1, "k", func() (int, error) {
+			return 1, f.Set(65, "k", 2)
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetOrSet deadlocked on another user's lock")
+	}
+}
+
+func TestLocksAreReleased(t *testing.T) {
+	var l userLocks
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			unlock := l.lock(userID % 3)
+			unlock()
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if len(l.locks) != 0 {
+		t.Fatalf("expected no locks, got %d", len(l.locks))
+	}
+}
+
+func TestLockSerializesUser(t *testing.T) {
+	var l userLocks
+
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := l.lock(1)
+			counter++
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counter != 100 {
+		t.Fatalf("expected 100, got %d", counter)
+	}
+}
@@ -0,0 +1,39 @@
+package fsm
+
+import "sync/atomic"
+
+// Metrics is a snapshot of the FSM counters
+type Metrics struct {
+	// Transitions is the number of successful transitions
+	Transitions uint64
+	// Failures is the number of failed transitions
+	Failures uint64
+	// CallbackPanics is the number of callbacks that panicked
+	CallbackPanics uint64
+	// ActiveUsers is the number of users with a state, -1 if the user state storage can't enumerate users
+	ActiveUsers int
+}
+
+// counters are the FSM counters accumulated since FSM creation
+type counters struct {
+	transitions atomic.Uint64
+	failures    atomic.Uint64
+	panics      atomic.Uint64
+}
+
+// Metrics returns the counters accumulated since FSM creation
+func (f *FSM[K, V]) Metrics() Metrics {
+	m := Metrics{
+		Transitions:    f.counters.transitions.Load(),
+		Failures:       f.counters.failures.Load(),
+		CallbackPanics: f.counters.panics.Load(),
+		ActiveUsers:    -1,
+	}
+
+	userIDs, err := f.users()
+	if err == nil {
+		m.ActiveUsers = len(userIDs)
+	}
+
+	return m
+}
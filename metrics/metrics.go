@@ -0,0 +1,128 @@
+// Package metrics records storage latency histograms and error counters reported by observable storages
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opasql/fsm"
+)
+
+// DefaultBuckets are default upper bounds of latency histogram buckets
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Label identifies a storage operation of a backend
+type Label struct {
+	// Backend is a name of the storage given to Hook, e.g. "redis"
+	Backend string
+	// Op is a name of the storage method, e.g. "Set"
+	Op string
+}
+
+// Histogram is a snapshot of operation latencies
+type Histogram struct {
+	// Buckets are upper bounds of the buckets
+	Buckets []time.Duration
+	// Counts are numbers of operations that took up to the bucket's bound and longer than the previous one,
+	// the last count is for operations longer than all bounds
+	Counts []uint64
+	// Count is the number of operations
+	Count uint64
+	// Sum is the total time of operations
+	Sum time.Duration
+}
+
+// Snapshot is a snapshot of the recorded metrics
+type Snapshot struct {
+	// Latency are latency histograms of completed operations
+	Latency map[Label]Histogram
+	// Errors are numbers of failed operations
+	Errors map[Label]uint64
+}
+
+// Recorder accumulates storage metrics, it's safe for concurrent use
+type Recorder struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	latency map[Label]*Histogram
+	errors  map[Label]uint64
+}
+
+// NewRecorder creates a recorder with the given latency bucket bounds, DefaultBuckets are used if none are given
+func NewRecorder(buckets ...time.Duration) *Recorder {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]time.Duration(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &Recorder{
+		buckets: sorted,
+		latency: make(map[Label]*Histogram),
+		errors:  make(map[Label]uint64),
+	}
+}
+
+// Hook returns a storage hook recording operations of the backend, pass it to the observable storages, e.g.
+// fsm.NewObservableUserStateStorage(storage, recorder.Hook("redis"))
+func (r *Recorder) Hook(backend string) fsm.StorageHook {
+	return func(event fsm.StorageEvent) {
+		if !event.Done {
+			return
+		}
+
+		r.record(Label{Backend: backend, Op: event.Op}, event.Duration, event.Err)
+	}
+}
+
+// record adds a completed operation
+func (r *Recorder) record(label Label, duration time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.latency[label]
+	if !ok {
+		h = &Histogram{Buckets: r.buckets, Counts: make([]uint64, len(r.buckets)+1)}
+		r.latency[label] = h
+	}
+
+	i := sort.Search(len(r.buckets), func(i int) bool { return duration <= r.buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += duration
+
+	if err != nil {
+		r.errors[label]++
+	}
+}
+
+// Snapshot returns the metrics recorded so far
+func (r *Recorder) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := Snapshot{
+		Latency: make(map[Label]Histogram, len(r.latency)),
+		Errors:  make(map[Label]uint64, len(r.errors)),
+	}
+	for label, h := range r.latency {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		s.Latency[label] = c
+	}
+	for label, n := range r.errors {
+		s.Errors[label] = n
+	}
+
+	return s
+}
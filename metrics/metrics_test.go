@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opasql/fsm"
+)
+
+var errTest = errors.New("test error")
+
+// stubStates is a user state storage taking delay to get a state and failing to set one
+type stubStates struct {
+	delay time.Duration
+}
+
+func (s stubStates) Set(int64, fsm.StateID) error { return errTest }
+
+func (s stubStates) Exists(int64) (bool, error) { return true, nil }
+
+func (s stubStates) Get(int64) (fsm.StateID, error) {
+	time.Sleep(s.delay)
+	return "a", nil
+}
+
+func TestRecorderRecordsLatency(t *testing.T) {
+	recorder := NewRecorder(time.Millisecond, time.Hour)
+	storage := fsm.NewObservableUserStateStorage(stubStates{delay: 2 * time.Millisecond}, recorder.Hook("stub"))
+
+	for range 2 {
+		if _, err := storage.Get(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, ok := recorder.Snapshot().Latency[Label{Backend: "stub", Op: "Get"}]
+	if !ok {
+		t.Fatal("expected latency of Get to be recorded")
+	}
+	if h.Count != 2 {
+		t.Fatalf("expected 2 operations, got %d", h.Count)
+	}
+	if h.Sum < 4*time.Millisecond {
+		t.Fatalf("expected at least 4ms in total, got %s", h.Sum)
+	}
+	if h.Counts[0] != 0 || h.Counts[1] != 2 {
+		t.Fatalf("expected operations in the second bucket, got %v", h.Counts)
+	}
+}
+
+func TestRecorderCountsErrors(t *testing.T) {
+	recorder := NewRecorder()
+	storage := fsm.NewObservableUserStateStorage(stubStates{}, recorder.Hook("stub"))
+
+	for range 3 {
+		if err := storage.Set(1, "b"); !errors.Is(err, errTest) {
+			t.Fatalf("expected stub error, got %v", err)
+		}
+	}
+	if _, err := storage.Exists(1); err != nil {
+		t.Fatal(err)
+	}
+
+	s := recorder.Snapshot()
+	if n := s.Errors[Label{Backend: "stub", Op: "Set"}]; n != 3 {
+		t.Fatalf("expected 3 errors of Set, got %d", n)
+	}
+	if n, ok := s.Errors[Label{Backend: "stub", Op: "Exists"}]; ok {
+		t.Fatalf("expected no errors of Exists, got %d", n)
+	}
+	if h := s.Latency[Label{Backend: "stub", Op: "Set"}]; h.Count != 3 {
+		t.Fatalf("expected failed operations in latency, got %d", h.Count)
+	}
+}
+
+func TestRecorderLabelsBackends(t *testing.T) {
+	recorder := NewRecorder()
+	primary := fsm.NewObservableUserStateStorage(stubStates{}, recorder.Hook("primary"))
+	secondary := fsm.NewObservableUserStateStorage(stubStates{}, recorder.Hook("secondary"))
+
+	_ = primary.Set(1, "b")
+	_, _ = secondary.Get(1)
+
+	s := recorder.Snapshot()
+	if s.Errors[Label{Backend: "primary", Op: "Set"}] != 1 {
+		t.Fatal("expected the error to be labeled with its backend")
+	}
+	if _, ok := s.Latency[Label{Backend: "primary", Op: "Get"}]; ok {
+		t.Fatal("expected Get of secondary not to be recorded for primary")
+	}
+	if h := s.Latency[Label{Backend: "secondary", Op: "Get"}]; h.Count != 1 {
+		t.Fatalf("expected 1 Get of secondary, got %d", h.Count)
+	}
+}
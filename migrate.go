@@ -0,0 +1,95 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// ProgressFunc is a function that reports progress of bulk operations
+type ProgressFunc func(done, total int)
+
+// Migrate copies state, region states, data, lists and locale of all users to the storages of dst FSM without firing callbacks.
+// Source storages must implement UserStateEnumerator and DataEnumerator,
+// dst data storage must implement DataLister if users have lists.
+// Existing users in dst are overwritten, so an interrupted migration can be safely run again.
+// The progress function is optional and called after each migrated user
+func (f *FSM[K, V]) Migrate(ctx context.Context, dst *FSM[K, V], progress ProgressFunc) error {
+	userIDs, err := f.users()
+	if err != nil {
+		return wrapError("migrate", 0, "", err)
+	}
+
+	for i, userID := range userIDs {
+		if err = ctx.Err(); err != nil {
+			return wrapError("migrate", 0, "", err)
+		}
+
+		err = f.migrateUser(dst, userID)
+		if err != nil {
+			return wrapError("migrate", userID, "", err)
+		}
+
+		if progress != nil {
+			progress(i+1, len(userIDs))
+		}
+	}
+
+	return nil
+}
+
+// migrateUser copies state, region states, data, lists and locale of the user to the storages of dst FSM
+func (f *FSM[K, V]) migrateUser(dst *FSM[K, V], userID int64) error {
+	stateID, err := f.userStates.Get(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	data, err := f.all(userID)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		err = dst.storage.Set(userID, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to set user data: %w", err)
+		}
+	}
+
+	lists, err := f.lists(userID)
+	if err != nil {
+		return err
+	}
+
+	err = dst.setLists(userID, lists)
+	if err != nil {
+		return err
+	}
+
+	locale, _, err := f.locale(userID)
+	if err != nil {
+		return err
+	}
+
+	err = dst.setLocale(userID, locale)
+	if err != nil {
+		return err
+	}
+
+	regions, err := f.regionStates(userID)
+	if err != nil {
+		return err
+	}
+
+	err = dst.setRegionStates(userID, regions)
+	if err != nil {
+		return err
+	}
+
+	err = dst.userStates.Set(userID, stateID)
+	if err != nil {
+		return fmt.Errorf("failed to set user state: %w", err)
+	}
+
+	return nil
+}
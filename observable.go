@@ -0,0 +1,342 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StorageEvent is an event of a storage operation passed to StorageHook
+type StorageEvent struct {
+	// Op is a name of the storage method, e.g. "Set"
+	Op string
+	// UserID is the user of the operation, 0 for operations over all users
+	UserID int64
+	// Key is the data key of the operation, nil if not applicable
+	Key any
+	// Done is false before the operation and true after it
+	Done bool
+	// Err is the error of the operation, always nil before it
+	Err error
+	// Duration is the time the operation took, always 0 before it
+	Duration time.Duration
+}
+
+// StorageHook is a function that will be called before and after each storage operation
+type StorageHook func(event StorageEvent)
+
+// observe calls the hook before and after fn
+func observe(hook StorageHook, event StorageEvent, fn func() error) error {
+	hook(event)
+
+	start := time.Now()
+	err := fn()
+
+	event.Done = true
+	event.Err = err
+	event.Duration = time.Since(start)
+	hook(event)
+
+	return err
+}
+
+// ObservableUserStateStorage is a user state storage decorator reporting every operation to a hook
+type ObservableUserStateStorage struct {
+	storage UserStateStorage
+	hook    StorageHook
+}
+
+// NewObservableUserStateStorage wraps a user state storage with a hook
+func NewObservableUserStateStorage(storage UserStateStorage, hook StorageHook) *ObservableUserStateStorage {
+	return &ObservableUserStateStorage{
+		storage: storage,
+		hook:    hook,
+	}
+}
+
+// Set sets user's state to the wrapped storage
+func (o *ObservableUserStateStorage) Set(userID int64, stateID StateID) error {
+	return observe(o.hook, StorageEvent{Op: "Set", UserID: userID}, func() error {
+		return o.storage.Set(userID, stateID)
+	})
+}
+
+// Exists checks whether user's state exists in the wrapped storage
+func (o *ObservableUserStateStorage) Exists(userID int64) (bool, error) {
+	var ok bool
+	err := observe(o.hook, StorageEvent{Op: "Exists", UserID: userID}, func() (err error) {
+		ok, err = o.storage.Exists(userID)
+		return err
+	})
+
+	return ok, err
+}
+
+// Get gets user's state from the wrapped storage
+func (o *ObservableUserStateStorage) Get(userID int64) (StateID, error) {
+	var stateID StateID
+	err := observe(o.hook, StorageEvent{Op: "Get", UserID: userID}, func() (err error) {
+		stateID, err = o.storage.Get(userID)
+		return err
+	})
+
+	return stateID, err
+}
+
+// Users returns all users from the wrapped storage if it implements UserStateEnumerator
+func (o *ObservableUserStateStorage) Users() ([]int64, error) {
+	var userIDs []int64
+	err := observe(o.hook, StorageEvent{Op: "Users"}, func() (err error) {
+		enumerator, ok := o.storage.(UserStateEnumerator)
+		if !ok {
+			return fmt.Errorf("%w: user state storage can't enumerate users", ErrNotSupported)
+		}
+
+		userIDs, err = enumerator.Users()
+		return err
+	})
+
+	return userIDs, err
+}
+
+// GetVersion gets user's state with its version from the wrapped storage,
+// the version is always 0 if it doesn't implement VersionedUserStateStorage
+func (o *ObservableUserStateStorage) GetVersion(userID int64) (StateID, uint64, error) {
+	var stateID StateID
+	var version uint64
+	err := observe(o.hook, StorageEvent{Op: "GetVersion", UserID: userID}, func() (err error) {
+		stateID, version, err = getState(o.storage, userID)
+		return err
+	})
+
+	return stateID, version, err
+}
+
+// SetIfVersion sets user's state to the wrapped storage if its version matches,
+// the version is ignored if it doesn't implement VersionedUserStateStorage
+func (o *ObservableUserStateStorage) SetIfVersion(userID int64, stateID StateID, expectedVersion uint64) (uint64, error) {
+	var version uint64
+	err := observe(o.hook, StorageEvent{Op: "SetIfVersion", UserID: userID}, func() (err error) {
+		version, err = setState(o.storage, userID, stateID, expectedVersion)
+		return err
+	})
+
+	return version, err
+}
+
+// Flush flushes the wrapped storage if it implements Flusher
+func (o *ObservableUserStateStorage) Flush() error {
+	return flush(o.storage)
+}
+
+// Close closes the wrapped storage if it implements io.Closer
+func (o *ObservableUserStateStorage) Close() error {
+	return closeStorage(o.storage)
+}
+
+// Ping pings the wrapped storage if it implements Pinger
+func (o *ObservableUserStateStorage) Ping(ctx context.Context) error {
+	return ping(ctx, o.storage)
+}
+
+// Warm warms the wrapped storage if it implements Warmer
+func (o *ObservableUserStateStorage) Warm(ctx context.Context, userIDs []int64) error {
+	return observe(o.hook, StorageEvent{Op: "Warm"}, func() error {
+		return warm(ctx, o.storage, userIDs)
+	})
+}
+
+// ObservableDataStorage is a data storage decorator reporting every operation to a hook
+type ObservableDataStorage[K comparable, V any] struct {
+	storage DataStorage[K, V]
+	hook    StorageHook
+}
+
+// NewObservableDataStorage wraps a data storage with a hook
+func NewObservableDataStorage[K comparable, V any](storage DataStorage[K, V], hook StorageHook) *ObservableDataStorage[K, V] {
+	return &ObservableDataStorage[K, V]{
+		storage: storage,
+		hook:    hook,
+	}
+}
+
+// Set sets user's data to the wrapped storage
+func (o *ObservableDataStorage[K, V]) Set(userID int64, key K, value V) error {
+	return observe(o.hook, StorageEvent{Op: "Set", UserID: userID, Key: key}, func() error {
+		return o.storage.Set(userID, key, value)
+	})
+}
+
+// Get gets user's data from the wrapped storage
+func (o *ObservableDataStorage[K, V]) Get(userID int64, key K) (V, error) {
+	var v V
+	err := observe(o.hook, StorageEvent{Op: "Get", UserID: userID, Key: key}, func() (err error) {
+		v, err = o.storage.Get(userID, key)
+		return err
+	})
+
+	return v, err
+}
+
+// Delete deletes user's data from the wrapped storage
+func (o *ObservableDataStorage[K, V]) Delete(userID int64, key K) error {
+	return observe(o.hook, StorageEvent{Op: "Delete", UserID: userID, Key: key}, func() error {
+		return o.storage.Delete(userID, key)
+	})
+}
+
+// All returns all user's data from the wrapped storage if it implements DataEnumerator
+func (o *ObservableDataStorage[K, V]) All(userID int64) (map[K]V, error) {
+	var data map[K]V
+	err := observe(o.hook, StorageEvent{Op: "All", UserID: userID}, func() (err error) {
+		enumerator, ok := o.storage.(DataEnumerator[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't enumerate user data", ErrNotSupported)
+		}
+
+		data, err = enumerator.All(userID)
+		return err
+	})
+
+	return data, err
+}
+
+// Lookup gets user's data from the wrapped storage if it implements DataLookuper
+func (o *ObservableDataStorage[K, V]) Lookup(userID int64, key K) (V, bool, error) {
+	var v V
+	var found bool
+	err := observe(o.hook, StorageEvent{Op: "Lookup", UserID: userID, Key: key}, func() (err error) {
+		lookuper, ok := o.storage.(DataLookuper[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't look up keys", ErrNotSupported)
+		}
+
+		v, found, err = lookuper.Lookup(userID, key)
+		return err
+	})
+
+	return v, found, err
+}
+
+// GetAndDelete gets and deletes user's data in the wrapped storage if it implements DataGetDeleter
+func (o *ObservableDataStorage[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	var v V
+	var found bool
+	err := observe(o.hook, StorageEvent{Op: "GetAndDelete", UserID: userID, Key: key}, func() (err error) {
+		getDeleter, ok := o.storage.(DataGetDeleter[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't get and delete keys", ErrNotSupported)
+		}
+
+		v, found, err = getDeleter.GetAndDelete(userID, key)
+		return err
+	})
+
+	return v, found, err
+}
+
+// Count counts user's keys in the wrapped storage if it implements DataCounter
+func (o *ObservableDataStorage[K, V]) Count(userID int64) (int, error) {
+	var n int
+	err := observe(o.hook, StorageEvent{Op: "Count", UserID: userID}, func() (err error) {
+		counter, ok := o.storage.(DataCounter)
+		if !ok {
+			return fmt.Errorf("%w: data storage can't count user data", ErrNotSupported)
+		}
+
+		n, err = counter.Count(userID)
+		return err
+	})
+
+	return n, err
+}
+
+// Append appends a value to user's list in the wrapped storage if it implements DataLister
+func (o *ObservableDataStorage[K, V]) Append(userID int64, key K, value V) error {
+	return observe(o.hook, StorageEvent{Op: "Append", UserID: userID, Key: key}, func() error {
+		lister, ok := o.storage.(DataLister[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+		}
+
+		return lister.Append(userID, key, value)
+	})
+}
+
+// List returns user's list from the wrapped storage if it implements DataLister
+func (o *ObservableDataStorage[K, V]) List(userID int64, key K) ([]V, error) {
+	var list []V
+	err := observe(o.hook, StorageEvent{Op: "List", UserID: userID, Key: key}, func() (err error) {
+		lister, ok := o.storage.(DataLister[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+		}
+
+		list, err = lister.List(userID, key)
+		return err
+	})
+
+	return list, err
+}
+
+// Lists returns all user's lists from the wrapped storage if it implements DataLister
+func (o *ObservableDataStorage[K, V]) Lists(userID int64) (map[K][]V, error) {
+	var lists map[K][]V
+	err := observe(o.hook, StorageEvent{Op: "Lists", UserID: userID}, func() (err error) {
+		lister, ok := o.storage.(DataLister[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+		}
+
+		lists, err = lister.Lists(userID)
+		return err
+	})
+
+	return lists, err
+}
+
+// SetList replaces user's list in the wrapped storage if it implements DataLister
+func (o *ObservableDataStorage[K, V]) SetList(userID int64, key K, list []V) error {
+	return observe(o.hook, StorageEvent{Op: "SetList", UserID: userID, Key: key}, func() error {
+		lister, ok := o.storage.(DataLister[K, V])
+		if !ok {
+			return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+		}
+
+		return lister.SetList(userID, key, list)
+	})
+}
+
+// Clear deletes all user's data from the wrapped storage if it implements DataClearer
+func (o *ObservableDataStorage[K, V]) Clear(userID int64) error {
+	return observe(o.hook, StorageEvent{Op: "Clear", UserID: userID}, func() error {
+		clearer, ok := o.storage.(DataClearer)
+		if !ok {
+			return fmt.Errorf("%w: data storage can't clear user data", ErrNotSupported)
+		}
+
+		return clearer.Clear(userID)
+	})
+}
+
+// Flush flushes the wrapped storage if it implements Flusher
+func (o *ObservableDataStorage[K, V]) Flush() error {
+	return flush(o.storage)
+}
+
+// Close closes the wrapped storage if it implements io.Closer
+func (o *ObservableDataStorage[K, V]) Close() error {
+	return closeStorage(o.storage)
+}
+
+// Ping pings the wrapped storage if it implements Pinger
+func (o *ObservableDataStorage[K, V]) Ping(ctx context.Context) error {
+	return ping(ctx, o.storage)
+}
+
+// Warm warms the wrapped storage if it implements Warmer
+func (o *ObservableDataStorage[K, V]) Warm(ctx context.Context, userIDs []int64) error {
+	return observe(o.hook, StorageEvent{Op: "Warm"}, func() error {
+		return warm(ctx, o.storage, userIDs)
+	})
+}
@@ -1,5 +1,11 @@
 package fsm
 
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
 // Option is a type for FSM options
 type Option[K comparable, V any] func(*FSM[K, V])
 
@@ -16,3 +22,224 @@ func WithDataStorage[K comparable, V any](storage DataStorage[K, V]) Option[K, V
 		fsm.storage = storage
 	}
 }
+
+// WithLocaleStorage sets locale storage FSM
+func WithLocaleStorage[K comparable, V any](storage LocaleStorage) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.locales = storage
+	}
+}
+
+// WithDefaultLocale sets the locale returned by Locale for users without one
+func WithDefaultLocale[K comparable, V any](locale string) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.defaultLocale = locale
+	}
+}
+
+// WithIdempotencyWindow sets how many processed idempotency keys are remembered per user by TransitionOnce
+func WithIdempotencyWindow[K comparable, V any](size int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		if size > 0 {
+			fsm.idempotencyWindow = size
+		}
+	}
+}
+
+// WithDefaultCallbackContext sets a function that derives the context passed to every callback.
+// The returned cancel function is called after the callback returns
+func WithDefaultCallbackContext[K comparable, V any](fn func(parent context.Context) (context.Context, context.CancelFunc)) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.callbackContext = fn
+	}
+}
+
+// WithClock sets a clock used by time dependent features
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.clock = clock
+	}
+}
+
+// WithStateRateLimit limits how often the state's callback can be called per user.
+// The limit is a number of callbacks per second, burst is a number of callbacks allowed at once
+func WithStateRateLimit[K comparable, V any](stateID StateID, limit float64, burst int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.rateLimits[stateID] = newRateLimiter(limit, burst)
+	}
+}
+
+// WithExplicitInit makes Current return ErrNoUserState for unknown users instead of seeding the initial state
+func WithExplicitInit[K comparable, V any](explicit bool) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.explicitInit = explicit
+	}
+}
+
+// WithExplicitStart makes Current return the initial state for an unknown user without storing it,
+// the user enters the machine when Start is called
+func WithExplicitStart[K comparable, V any]() Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.explicitStart = true
+	}
+}
+
+// WithInitialStateFunc sets a function computing the initial state per user,
+// it's used instead of the initial state passed to New when a user is seeded or reset
+func WithInitialStateFunc[K comparable, V any](fn func(userID int64) StateID) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.initialStateFunc = fn
+	}
+}
+
+// WithRunInitialOnSeed makes Current call the callback of the initial state when it seeds an unknown user,
+// with a background context and no args. The callback is called after the user's lock is released,
+// if it transitions the user, Current still returns the initial state. A callback error is returned
+// by Current, the user stays seeded and the callback isn't called again. The state is entered like
+// a transition target: args, rate limits and deferral apply, and observers get the initial state as from and to
+func WithRunInitialOnSeed[K comparable, V any]() Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.runInitialOnSeed = true
+	}
+}
+
+// WithRegionStorage sets a user state storage for an orthogonal region
+func WithRegionStorage[K comparable, V any](region string, storage UserStateStorage) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.regions[region] = storage
+	}
+}
+
+// WithTransitionObserver adds an observer called after every transition
+func WithTransitionObserver[K comparable, V any](observer TransitionObserverCallback) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.globalObservers = append(fsm.globalObservers, observer)
+	}
+}
+
+// WithVariantKey sets the reserved data key holding the user's variant of the random transition from a state,
+// it's required if K can't hold the default "fsm/variant/<from>" keys
+func WithVariantKey[K comparable, V any](key func(from StateID) K) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.variantKeys = key
+	}
+}
+
+// WithRand sets a random number generator used by random transitions
+func WithRand[K comparable, V any](r *rand.Rand) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.rand = r
+	}
+}
+
+// WithCallbackConcurrency limits the number of callbacks running at the same time across all users
+func WithCallbackConcurrency[K comparable, V any](n int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		if n > 0 {
+			fsm.callbackSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithBeforeTransition adds a hook called before every state change, an error of the hook aborts the transition.
+// The hook is called under the user's lock, so it must not change the same user through FSM,
+// other users are locked separately
+func WithBeforeTransition[K comparable, V any](hook BeforeTransitionHook) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.beforeHooks = append(fsm.beforeHooks, hook)
+	}
+}
+
+// WithAfterTransition adds a hook called after every transition, successful or not
+func WithAfterTransition[K comparable, V any](hook AfterTransitionHook) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.afterHooks = append(fsm.afterHooks, hook)
+	}
+}
+
+// WithUserQueues makes transitions of each user run one by one in arrival order in a per-user goroutine,
+// Transition blocks until its turn is processed. Transitions chained from a callback run at once
+// in the same goroutine. A transition whose context is done before its turn is dropped without running.
+// The goroutine exits as soon as the user's queue is empty
+func WithUserQueues[K comparable, V any](bufferSize int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.queues = newUserQueues(bufferSize)
+	}
+}
+
+// WithStickyStates marks states as sticky, a transition of a user already in a sticky state
+// to the same state is skipped without calling the callback and the observers.
+// Data of CommitAndTransition is still written
+func WithStickyStates[K comparable, V any](stateIDs ...StateID) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		for _, stateID := range stateIDs {
+			fsm.sticky[stateID] = true
+		}
+	}
+}
+
+// WithMaxKeysPerUser limits the number of distinct keys a user can store, Set of a new key
+// returns ErrTooManyKeys once the limit is reached, lists aren't limited. Data storage must implement DataLookuper and DataCounter
+func WithMaxKeysPerUser[K comparable, V any](n int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.maxKeys = n
+	}
+}
+
+// WithErrorHandler sets a handler of the errors of deferred callbacks
+func WithErrorHandler[K comparable, V any](handler ErrorHandler) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.errorHandler = handler
+	}
+}
+
+// WithValueTransformer sets functions applied to values on their way to and from data storage,
+// e.g. to encrypt them at rest. FSM methods see the decoded values, an error of a transformer is returned
+func WithValueTransformer[K comparable, V any](encode, decode ValueTransformer[V]) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.encode = encode
+		fsm.decode = decode
+	}
+}
+
+// WithPanicRecoveryState makes FSM recover callback panics, they are returned as ErrCallbackPanic.
+// If the callback of a transition panics, the transition is rolled back and the user is transitioned
+// to the recovery state, its callback is called. A panic of the recovery state's callback is only returned
+func WithPanicRecoveryState[K comparable, V any](stateID StateID) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.recoveryState = stateID
+	}
+}
+
+// WithoutInitialCallback acknowledges that the initial state has no callback, Validate doesn't report it
+func WithoutInitialCallback[K comparable, V any]() Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.noInitialCallback = true
+	}
+}
+
+// WithMaxTransitionDepth limits the depth of transitions chained from callbacks, 32 by default.
+// A transition over the limit returns ErrTransitionLoop, a limit <= 0 disables the check
+func WithMaxTransitionDepth[K comparable, V any](n int) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.maxDepth = n
+	}
+}
+
+// WithProposalTTL sets how long a transition staged by ProposeTransition waits for confirmation
+func WithProposalTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		fsm.proposalTTL = ttl
+	}
+}
+
+// WithAuthorizedStates protects states with authorizers, a transition into a protected state
+// returns ErrUnauthorized if its authorizer denies the user. Authorizers are called under the user's lock,
+// so they must not change the same user through FSM
+func WithAuthorizedStates[K comparable, V any](authorizers map[StateID]Authorizer) Option[K, V] {
+	return func(fsm *FSM[K, V]) {
+		for stateID, authorizer := range authorizers {
+			fsm.authorizers[stateID] = authorizer
+		}
+	}
+}
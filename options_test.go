@@ -0,0 +1,273 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDefaultCallbackContext(t *testing.T) {
+	type ctxKey struct{}
+
+	var canceled, decorated bool
+	var callbackCtx context.Context
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(ctx context.Context, _ ...any) error {
+			decorated = ctx.Value(ctxKey{}) == "decorated"
+			callbackCtx = ctx
+			return nil
+		},
+	}, WithDefaultCallbackContext[string, int](func(parent context.Context) (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.WithValue(parent, ctxKey{}, "decorated"))
+		return ctx, func() {
+			canceled = true
+			cancel()
+		}
+	}))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Transition(context.Background(), 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !decorated {
+		t.Fatal("expected the decorated context to reach the callback")
+	}
+	if !canceled || callbackCtx.Err() == nil {
+		t.Fatal("expected the callback context to be canceled after the callback")
+	}
+}
+
+func TestExplicitInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit bool
+	}{
+		{name: "auto seed", explicit: false},
+		{name: "explicit init", explicit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("a", nil, WithExplicitInit[string, int](tt.explicit))
+
+			stateID, err := f.Current(1)
+			if tt.explicit {
+				if !errors.Is(err, ErrNoUserState) {
+					t.Fatalf("expected ErrNoUserState, got %v", err)
+				}
+				if err := f.Init(1); err != nil {
+					t.Fatal(err)
+				}
+				stateID, err = f.Current(1)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stateID != "a" {
+				t.Fatalf("expected state a, got %s", stateID)
+			}
+			if got := mustState(t, f, 1); got != "a" {
+				t.Fatalf("expected the user to be seeded, got %s", got)
+			}
+		})
+	}
+}
+
+func TestMaxKeysPerUser(t *testing.T) {
+	for name, storage := range dataStorages {
+		t.Run(name, func(t *testing.T) {
+			f := New[string, int]("a", nil,
+				WithDataStorage[string, int](storage()),
+				WithMaxKeysPerUser[string, int](2),
+			)
+
+			for _, key := range []string{"a", "b"} {
+				if err := f.Set(1, key, 1); err != nil {
+					t.Fatalf("expected key %s to fit the limit, got %v", key, err)
+				}
+			}
+
+			if err := f.Set(1, "c", 1); !errors.Is(err, ErrTooManyKeys) {
+				t.Fatalf("expected ErrTooManyKeys, got %v", err)
+			}
+			if err := f.Set(1, "a", 2); err != nil {
+				t.Fatalf("expected an update at the limit to work, got %v", err)
+			}
+			if v, err := f.Get(1, "a"); err != nil || v != 2 {
+				t.Fatalf("expected 2, got %d, %v", v, err)
+			}
+			if err := f.Set(2, "c", 1); err != nil {
+				t.Fatalf("expected the limit to be per user, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExplicitStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option[string, int]
+		written bool
+	}{
+		{name: "implicit", written: true},
+		{name: "explicit start", opts: []Option[string, int]{WithExplicitStart[string, int]()}, written: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			f := New[string, int]("start", map[StateID]Callback{
+				"start": func(context.Context, ...any) error {
+					calls++
+					return nil
+				},
+			}, tt.opts...)
+
+			stateID, err := f.Current(1)
+			if err != nil || stateID != "start" {
+				t.Fatalf("expected start, got %s, %v", stateID, err)
+			}
+			ok, err := f.userStates.Exists(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.written {
+				t.Fatalf("expected the user to be stored: %t, got %t", tt.written, ok)
+			}
+			if calls != 0 {
+				t.Fatalf("expected Current not to call the callback, got %d calls", calls)
+			}
+
+			if err := f.Start(context.Background(), 1); err != nil {
+				t.Fatal(err)
+			}
+			if got := mustState(t, f, 1); got != "start" {
+				t.Fatalf("expected Start to store start, got %s", got)
+			}
+			if calls != 1 {
+				t.Fatalf("expected Start to call the callback, got %d calls", calls)
+			}
+		})
+	}
+}
+
+func TestInitialStateFunc(t *testing.T) {
+	returning := func(userID int64) StateID {
+		if userID == 2 {
+			return "menu"
+		}
+		return "onboarding"
+	}
+
+	f := New[string, int]("start", map[StateID]Callback{"other": noop}, WithInitialStateFunc[string, int](returning))
+
+	for userID, want := range map[int64]StateID{1: "onboarding", 2: "menu"} {
+		stateID, err := f.Current(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stateID != want {
+			t.Fatalf("expected user %d to start in %s, got %s", userID, want, stateID)
+		}
+
+		if err := f.Transition(context.Background(), userID, "other"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Reset(userID); err != nil {
+			t.Fatal(err)
+		}
+		if got := mustState(t, f, userID); got != want {
+			t.Fatalf("expected user %d to be reset to %s, got %s", userID, want, got)
+		}
+	}
+
+	static := New[string, int]("start", nil)
+	if stateID, err := static.Current(1); err != nil || stateID != "start" {
+		t.Fatalf("expected the static initial state, got %s, %v", stateID, err)
+	}
+}
+
+func TestPanicRecoveryState(t *testing.T) {
+	ctx := context.Background()
+
+	var recovered bool
+	f := New[string, int]("a", map[StateID]Callback{
+		"b": func(context.Context, ...any) error { panic("boom") },
+		"error": func(context.Context, ...any) error {
+			recovered = true
+			return nil
+		},
+	}, WithPanicRecoveryState[string, int]("error"))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(ctx, 1, "b")
+	if !errors.Is(err, ErrCallbackPanic) {
+		t.Fatalf("expected ErrCallbackPanic, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "error" {
+		t.Fatalf("expected the user to land in the recovery state, got %s", got)
+	}
+	if !recovered {
+		t.Fatal("expected the recovery state's callback to be called")
+	}
+	if m := f.Metrics(); m.CallbackPanics != 1 {
+		t.Fatalf("expected 1 panic, got %d", m.CallbackPanics)
+	}
+}
+
+func TestPanicRecoveryStatePanics(t *testing.T) {
+	f := New[string, int]("a", map[StateID]Callback{
+		"error": func(context.Context, ...any) error { panic("boom") },
+	}, WithPanicRecoveryState[string, int]("error"))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(context.Background(), 1, "error")
+	if !errors.Is(err, ErrCallbackPanic) {
+		t.Fatalf("expected ErrCallbackPanic, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected the panic of the recovery state to roll back, got %s", got)
+	}
+}
+
+func TestMaxTransitionDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option[string, int]
+		depth int
+	}{
+		{name: "default", depth: defaultMaxTransitionDepth},
+		{name: "custom", opts: []Option[string, int]{WithMaxTransitionDepth[string, int](5)}, depth: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f *FSM[string, int]
+			var calls int
+			f = New[string, int]("a", map[StateID]Callback{
+				"loop": func(ctx context.Context, _ ...any) error {
+					calls++
+					return f.Transition(ctx, 1, "loop")
+				},
+			}, tt.opts...)
+			if err := f.Init(1); err != nil {
+				t.Fatal(err)
+			}
+
+			err := f.Transition(context.Background(), 1, "loop")
+			if !errors.Is(err, ErrTransitionLoop) {
+				t.Fatalf("expected ErrTransitionLoop, got %v", err)
+			}
+			if calls != tt.depth {
+				t.Fatalf("expected %d callbacks before the limit, got %d", tt.depth, calls)
+			}
+			if got := mustState(t, f, 1); got != "a" {
+				t.Fatalf("expected the loop to roll back, got %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package fsm
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// callbackPattern is a callback for the states matching a pattern
+type callbackPattern struct {
+	pattern  string
+	callback Callback
+}
+
+// AddCallbackPattern adds a callback for the states matching the pattern, see path.Match for the syntax.
+// A callback added for a state by AddCallback wins over the patterns, if several patterns match,
+// the first added one is used. A nil callback removes the pattern
+func (f *FSM[K, V]) AddCallbackPattern(pattern string, callback Callback) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid callback pattern %q: %w", pattern, err)
+	}
+
+	f.cbMu.Lock()
+	defer f.cbMu.Unlock()
+
+	i := slices.IndexFunc(f.patterns, func(p callbackPattern) bool { return p.pattern == pattern })
+
+	switch {
+	case callback == nil && i >= 0:
+		f.patterns = slices.Delete(f.patterns, i, i+1)
+	case callback == nil:
+	case i >= 0:
+		f.patterns[i].callback = callback
+	default:
+		f.patterns = append(f.patterns, callbackPattern{pattern: pattern, callback: callback})
+	}
+
+	return nil
+}
+
+// callback returns the callback of the state, falling back to the first matching pattern
+func (f *FSM[K, V]) callback(stateID StateID) (Callback, bool) {
+	f.cbMu.RLock()
+	defer f.cbMu.RUnlock()
+
+	if cb, ok := f.callbacks[stateID]; ok {
+		return cb, true
+	}
+
+	for _, p := range f.patterns {
+		if ok, _ := path.Match(p.pattern, string(stateID)); ok {
+			return p.callback, true
+		}
+	}
+
+	return nil, false
+}
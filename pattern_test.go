@@ -0,0 +1,45 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCallbackPattern(t *testing.T) {
+	ctx := context.Background()
+
+	var called []string
+	f := New[string, int]("start", map[StateID]Callback{
+		"step2": func(context.Context, ...any) error {
+			called = append(called, "exact")
+			return nil
+		},
+	})
+	if err := f.AddCallbackPattern("step*", func(context.Context, ...any) error {
+		called = append(called, "pattern")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stateID := range []StateID{"step1", "step2", "other"} {
+		if err := f.Transition(ctx, 1, stateID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(called) != 2 || called[0] != "pattern" || called[1] != "exact" {
+		t.Fatalf("expected the pattern for step1 and the exact callback for step2, got %v", called)
+	}
+}
+
+func TestCallbackPatternInvalid(t *testing.T) {
+	f := New[string, int]("start", nil)
+
+	if err := f.AddCallbackPattern("step[", noop); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package fsm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// defaultProposalTTL is a default time a proposed transition waits for confirmation
+const defaultProposalTTL = 5 * time.Minute
+
+// proposal is a staged transition waiting for confirmation
+type proposal struct {
+	token   string
+	stateID StateID
+	expires time.Time
+}
+
+// ProposeTransition stages a transition of the user to the state and returns a token confirming it.
+// The proposal expires after WithProposalTTL, 5 minutes by default, a new proposal replaces the previous one
+func (f *FSM[K, V]) ProposeTransition(userID int64, stateID StateID) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", wrapError("propose transition", userID, stateID, fmt.Errorf("failed to generate token: %w", err))
+	}
+	token := hex.EncodeToString(b)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock.Now()
+	if len(f.proposals) >= max(minSweepSize, f.proposalSweepAt) {
+		for id, p := range f.proposals {
+			if !now.Before(p.expires) {
+				delete(f.proposals, id)
+			}
+		}
+		f.proposalSweepAt = 2 * len(f.proposals)
+	}
+
+	f.proposals[userID] = proposal{
+		token:   token,
+		stateID: stateID,
+		expires: now.Add(f.proposalTTL),
+	}
+
+	return token, nil
+}
+
+// ConfirmTransition commits the transition staged by ProposeTransition. A proposal is used once,
+// ErrInvalidProposal is returned for an unknown token and ErrProposalExpired for an expired proposal
+func (f *FSM[K, V]) ConfirmTransition(ctx context.Context, userID int64, token string, args ...any) error {
+	f.mu.Lock()
+	p, ok := f.proposals[userID]
+	if ok && p.token == token {
+		delete(f.proposals, userID)
+	}
+	now := f.clock.Now()
+	f.mu.Unlock()
+
+	if !ok || p.token != token {
+		return wrapError("confirm transition", userID, "", ErrInvalidProposal)
+	}
+	if !now.Before(p.expires) {
+		return wrapError("confirm transition", userID, p.stateID, ErrProposalExpired)
+	}
+
+	return f.Transition(ctx, userID, p.stateID, args...)
+}
@@ -0,0 +1,80 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProposeConfirmTransition(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	newFSM := func() *FSM[string, int] {
+		f := New[string, int]("a", map[StateID]Callback{"delete": noop},
+			WithClock[string, int](clock), WithProposalTTL[string, int](time.Minute))
+		if err := f.Init(1); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		f := newFSM()
+		token, err := f.ProposeTransition(1, "delete")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mustState(t, f, 1); got != "a" {
+			t.Fatalf("expected the proposal not to transition, got %s", got)
+		}
+
+		if err := f.ConfirmTransition(ctx, 1, token); err != nil {
+			t.Fatal(err)
+		}
+		if got := mustState(t, f, 1); got != "delete" {
+			t.Fatalf("expected the confirmed transition, got %s", got)
+		}
+		if err := f.ConfirmTransition(ctx, 1, token); !errors.Is(err, ErrInvalidProposal) {
+			t.Fatalf("expected the proposal to be used once, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		f := newFSM()
+		token, err := f.ProposeTransition(1, "delete")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.now = clock.now.Add(time.Minute)
+
+		if err := f.ConfirmTransition(ctx, 1, token); !errors.Is(err, ErrProposalExpired) {
+			t.Fatalf("expected ErrProposalExpired, got %v", err)
+		}
+		if got := mustState(t, f, 1); got != "a" {
+			t.Fatalf("expected the expired proposal to be canceled, got %s", got)
+		}
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		f := newFSM()
+		token, err := f.ProposeTransition(1, "delete")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := f.ConfirmTransition(ctx, 1, "wrong"); !errors.Is(err, ErrInvalidProposal) {
+			t.Fatalf("expected ErrInvalidProposal, got %v", err)
+		}
+		if err := f.ConfirmTransition(ctx, 2, token); !errors.Is(err, ErrInvalidProposal) {
+			t.Fatalf("expected the token of another user to be rejected, got %v", err)
+		}
+		if got := mustState(t, f, 1); got != "a" {
+			t.Fatalf("expected no transition, got %s", got)
+		}
+		if err := f.ConfirmTransition(ctx, 1, token); err != nil {
+			t.Fatalf("expected a wrong token not to cancel the proposal, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+)
+
+// queueKey is a context key holding the user whose queue runs the transition
+type queueKey struct{}
+
+// userQueues runs transitions of each user one by one in arrival order
+type userQueues struct {
+	mu      sync.Mutex
+	size    int
+	workers map[int64]*userWorker
+}
+
+// userWorker is a goroutine processing the queue of a single user
+type userWorker struct {
+	jobs    chan queueJob
+	pending int
+}
+
+// queueJob is a transition waiting in the user's queue
+type queueJob struct {
+	ctx    context.Context
+	fn     func(ctx context.Context) error
+	result chan error
+}
+
+// newUserQueues creates user queues with the given buffer size
+func newUserQueues(size int) *userQueues {
+	return &userQueues{
+		size:    size,
+		workers: make(map[int64]*userWorker),
+	}
+}
+
+// do enqueues fn to the user's queue and waits for its result or ctx done.
+// If the call is made from the user's queue, e.g. by a chained transition, fn is run at once
+func (q *userQueues) do(ctx context.Context, userID int64, fn func(ctx context.Context) error) error {
+	if current, ok := ctx.Value(queueKey{}).(int64); ok && current == userID {
+		return fn(ctx)
+	}
+
+	job := queueJob{
+		ctx:    context.WithValue(ctx, queueKey{}, userID),
+		fn:     fn,
+		result: make(chan error, 1),
+	}
+
+	q.mu.Lock()
+	w, ok := q.workers[userID]
+	if !ok {
+		w = &userWorker{jobs: make(chan queueJob, q.size)}
+		q.workers[userID] = w
+		go q.run(userID, w)
+	}
+	w.pending++
+	q.mu.Unlock()
+
+	select {
+	case w.jobs <- job:
+	case <-ctx.Done():
+		q.mu.Lock()
+		w.pending--
+		if w.pending == 0 {
+			delete(q.workers, userID)
+			close(w.jobs)
+		}
+		q.mu.Unlock()
+
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-job.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run processes the user's jobs, the worker exits as soon as its queue is empty.
+// Jobs whose context is done while waiting in the queue are skipped
+func (q *userQueues) run(userID int64, w *userWorker) {
+	for job := range w.jobs {
+		if err := job.ctx.Err(); err != nil {
+			job.result <- err
+		} else {
+			job.result <- job.fn(job.ctx)
+		}
+
+		q.mu.Lock()
+		w.pending--
+		if w.pending == 0 {
+			delete(q.workers, userID)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,163 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueRunsInOrder(t *testing.T) {
+	q := newUserQueues(5)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var order []int
+	var mu sync.Mutex
+
+	go func() {
+		_ = q.do(context.Background(), 1, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = q.do(context.Background(), 1, func(context.Context) error {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				return nil
+			})
+		}(i)
+		// let the job reach the queue before enqueueing the next one
+		waitQueued(t, q, 1, i+1)
+	}
+	close(release)
+	wg.Wait()
+
+	if len(order) != 5 {
+		t.Fatalf("expected 5 jobs, got %v", order)
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("expected order 0..4, got %v", order)
+		}
+	}
+}
+
+func TestQueueSkipsCanceledJobs(t *testing.T) {
+	q := newUserQueues(1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = q.do(context.Background(), 1, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- q.do(ctx, 1, func(context.Context) error {
+			ran <- struct{}{}
+			return nil
+		})
+	}()
+	waitPending(t, q, 1, 2)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	close(release)
+	waitPending(t, q, 1, 0)
+
+	select {
+	case <-ran:
+		t.Fatal("canceled job ran")
+	default:
+	}
+}
+
+func TestQueueCanceledSendReapsWorker(t *testing.T) {
+	q := newUserQueues(0)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = q.do(context.Background(), 1, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// the worker is busy and the queue is unbuffered, so the send blocks until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.do(ctx, 1, func(context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	close(release)
+	waitPending(t, q, 1, 0)
+
+	if err := q.do(context.Background(), 1, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	waitPending(t, q, 1, 0)
+}
+
+// waitQueued waits until the user's queue buffers n jobs
+func waitQueued(t *testing.T, q *userQueues, userID int64, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		queued := len(q.workers[userID].jobs)
+		q.mu.Unlock()
+
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d queued jobs", n)
+}
+
+// waitPending waits until the user's queue has n pending jobs, 0 means the worker is gone
+func waitPending(t *testing.T, q *userQueues, userID int64, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		w, ok := q.workers[userID]
+		pending := 0
+		if ok {
+			pending = w.pending
+		}
+		q.mu.Unlock()
+
+		if pending == n && ok == (n > 0) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d pending jobs", n)
+}
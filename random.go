@@ -0,0 +1,152 @@
+package fsm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// randomTarget is a weighted target of a random transition
+type randomTarget struct {
+	stateID StateID
+	weight  int
+}
+
+// AddRandomTransition adds a weighted random transition from a state to one of the targets.
+// Targets with non-positive weights are never picked
+func (f *FSM[K, V]) AddRandomTransition(from StateID, targets map[StateID]int) {
+	list := make([]randomTarget, 0, len(targets))
+	for stateID, weight := range targets {
+		if weight > 0 {
+			list = append(list, randomTarget{stateID: stateID, weight: weight})
+		}
+	}
+
+	slices.SortFunc(list, func(a, b randomTarget) int {
+		return cmp.Compare(a.stateID, b.stateID)
+	})
+
+	f.randomTransitions[from] = list
+}
+
+// variantKeyPrefix is a prefix of the reserved data keys holding the picked variants of random transitions
+const variantKeyPrefix = "fsm/variant/"
+
+// TransitionRandom transitions the user to a weighted random target of the random transition from a state.
+// The picked target is stored in the user's data under a reserved key, "fsm/variant/<from>" by default,
+// so the user always gets the same variant, also after a restart, and it's kept by Dump and Migrate.
+// Data storage must implement DataLookuper and V must hold strings, e.g. string or any
+func (f *FSM[K, V]) TransitionRandom(ctx context.Context, userID int64, from StateID, args ...any) error {
+	stateID, err := f.variant(userID, from)
+	if err != nil {
+		return wrapError("transition random", userID, "", err)
+	}
+
+	return f.Transition(ctx, userID, stateID, args...)
+}
+
+// variant returns the user's target of the random transition, picking and storing it on first call
+func (f *FSM[K, V]) variant(userID int64, from StateID) (StateID, error) {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	key, err := f.variantKey(from)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok, err := f.lookup(userID, key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if stateID, ok := stringOf(v); ok {
+			return StateID(stateID), nil
+		}
+	}
+
+	stateID, err := f.pick(from)
+	if err != nil {
+		return "", err
+	}
+
+	v, err = fromString[V](string(stateID))
+	if err != nil {
+		return "", err
+	}
+
+	err = f.storage.Set(userID, key, v)
+	if err != nil {
+		return "", fmt.Errorf("failed to set user variant: %w", err)
+	}
+
+	return stateID, nil
+}
+
+// variantKey returns the reserved data key of the variant of the random transition from a state
+func (f *FSM[K, V]) variantKey(from StateID) (K, error) {
+	if f.variantKeys != nil {
+		return f.variantKeys(from), nil
+	}
+
+	return fromString[K](variantKeyPrefix + string(from))
+}
+
+// pick picks a weighted random target of the random transition from a state
+func (f *FSM[K, V]) pick(from StateID) (StateID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	targets := f.randomTransitions[from]
+
+	var total int
+	for _, target := range targets {
+		total += target.weight
+	}
+	if total == 0 {
+		return "", fmt.Errorf("%w: from state: %s", ErrNoRandomTransition, from)
+	}
+
+	n := f.rand.Intn(total)
+
+	for _, target := range targets {
+		if n < target.weight {
+			return target.stateID, nil
+		}
+		n -= target.weight
+	}
+
+	return "", fmt.Errorf("%w: from state: %s", ErrNoRandomTransition, from)
+}
+
+// fromString converts a string to T, T must have a string underlying type or be an interface a string implements
+func fromString[T any](s string) (T, error) {
+	var t T
+
+	v := reflect.ValueOf(&t).Elem()
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Interface && reflect.TypeOf(s).Implements(v.Type()):
+		v.Set(reflect.ValueOf(s))
+	default:
+		return t, fmt.Errorf("%w: %s can't hold a variant", ErrNotSupported, v.Type())
+	}
+
+	return t, nil
+}
+
+// stringOf returns the string held by t, false if t doesn't hold a string
+func stringOf[T any](t T) (string, bool) {
+	v := reflect.ValueOf(&t).Elem()
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() != reflect.String {
+		return "", false
+	}
+
+	return v.String(), true
+}
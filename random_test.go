@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestTransitionRandomDistribution(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, string]("start", nil, WithRand[string, string](rand.New(rand.NewSource(1))))
+	f.AddRandomTransition("start", map[StateID]int{"a": 1, "b": 3, "never": 0})
+
+	counts := map[StateID]int{}
+	for userID := int64(0); userID < 1000; userID++ {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.TransitionRandom(ctx, userID, "start"); err != nil {
+			t.Fatal(err)
+		}
+		counts[mustState(t, f, userID)]++
+	}
+
+	if counts["never"] != 0 {
+		t.Fatalf("expected zero weight target to be never picked, got %d", counts["never"])
+	}
+	if counts["b"] < 700 || counts["b"] > 800 {
+		t.Fatalf("expected about 750 users in b, got %v", counts)
+	}
+}
+
+func TestTransitionRandomIsSticky(t *testing.T) {
+	ctx := context.Background()
+	storage := initialDataStorage[string, string]()
+
+	newFSM := func(seed int64) *FSM[string, string] {
+		f := New[string, string]("start", nil,
+			WithDataStorage[string, string](storage),
+			WithRand[string, string](rand.New(rand.NewSource(seed))),
+		)
+		f.AddRandomTransition("start", map[StateID]int{"a": 1, "b": 1})
+
+		return f
+	}
+
+	f := newFSM(1)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRandom(ctx, 1, "start"); err != nil {
+		t.Fatal(err)
+	}
+	variant := mustState(t, f, 1)
+
+	v, err := f.Get(1, "fsm/variant/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if StateID(v) != variant {
+		t.Fatalf("expected stored variant %s, got %s", variant, v)
+	}
+
+	// a restarted FSM with other random numbers reads the stored variant
+	for seed := int64(2); seed < 10; seed++ {
+		restarted := newFSM(seed)
+		if err := restarted.Init(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := restarted.TransitionRandom(ctx, 1, "start"); err != nil {
+			t.Fatal(err)
+		}
+		if got := mustState(t, restarted, 1); got != variant {
+			t.Fatalf("expected sticky variant %s, got %s", variant, got)
+		}
+	}
+}
+
+func TestTransitionRandomVariantKey(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[int, any]("start", nil)
+	f.AddRandomTransition("start", map[StateID]int{"a": 1})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRandom(ctx, 1, "start"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported for int keys, got %v", err)
+	}
+
+	f = New[int, any]("start", nil, WithVariantKey[int, any](func(StateID) int { return -1 }))
+	f.AddRandomTransition("start", map[StateID]int{"a": 1})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRandom(ctx, 1, "start"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.Get(1, -1); v != "a" {
+		t.Fatalf("expected variant a under the custom key, got %v", v)
+	}
+}
@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"math"
+	"time"
+)
+
+// minSweepSize is a number of per user entries at which stale ones are swept for the first time
+const minSweepSize = 1024
+
+// rateLimiter is a per user token bucket limiter
+type rateLimiter struct {
+	limit   float64
+	burst   int
+	buckets map[int64]*bucket
+	// sweepAt is the number of buckets at which full buckets are swept
+	sweepAt int
+}
+
+// bucket is a token bucket of a single user
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing limit events per second with the given burst
+func newRateLimiter(limit float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		burst:   burst,
+		buckets: make(map[int64]*bucket),
+		sweepAt: minSweepSize,
+	}
+}
+
+// allow takes a token from the user's bucket, it returns false if the bucket is empty
+func (r *rateLimiter) allow(userID int64, now time.Time) bool {
+	b := r.refill(userID, now)
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// refill returns the user's bucket topped up for the time elapsed since the last refill
+func (r *rateLimiter) refill(userID int64, now time.Time) *bucket {
+	b, ok := r.buckets[userID]
+	if !ok {
+		if len(r.buckets) >= r.sweepAt {
+			r.sweep(now)
+		}
+
+		b = &bucket{tokens: float64(r.burst), last: now}
+		r.buckets[userID] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(r.burst), b.tokens+elapsed*r.limit)
+		b.last = now
+	}
+
+	return b
+}
+
+// sweep deletes the buckets that would be full by now, they are recreated full when needed
+func (r *rateLimiter) sweep(now time.Time) {
+	for userID, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*r.limit >= float64(r.burst) {
+			delete(r.buckets, userID)
+		}
+	}
+
+	r.sweepAt = max(minSweepSize, 2*len(r.buckets))
+}
+
+// allowCallback checks the rate limit of the state's callback for the user
+func (f *FSM[K, V]) allowCallback(userID int64, stateID StateID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	limiter, ok := f.rateLimits[stateID]
+	if !ok {
+		return true
+	}
+
+	return limiter.allow(userID, f.clock.Now())
+}
+
+// peekCallback reports whether the state's callback would be allowed for the user without taking a token
+func (f *FSM[K, V]) peekCallback(userID int64, stateID StateID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	limiter, ok := f.rateLimits[stateID]
+	if !ok {
+		return true
+	}
+
+	return limiter.refill(userID, f.clock.Now()).tokens >= 1
+}
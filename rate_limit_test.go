@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var calls int
+	f := New[string, int]("a", map[StateID]Callback{
+		"a": noop,
+		"b": func(context.Context, ...any) error {
+			calls++
+			return nil
+		},
+	}, WithClock[string, int](clock), WithStateRateLimit[string, int]("b", 1, 2))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	enter := func() error {
+		if err := f.Transition(ctx, 1, "a"); err != nil {
+			t.Fatal(err)
+		}
+		return f.Transition(ctx, 1, "b")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := enter(); err != nil {
+			t.Fatalf("expected the burst to be allowed, got %v", err)
+		}
+	}
+
+	err := enter()
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "b" {
+		t.Fatalf("expected the state to be set, got %s", got)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the limited callback to be skipped, got %d calls", calls)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if err := enter(); err != nil {
+		t.Fatalf("expected a token after a second, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestStateRateLimitIsPerUser(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("a", map[StateID]Callback{"b": noop},
+		WithClock[string, int](&fakeClock{now: time.Unix(0, 0)}), WithStateRateLimit[string, int]("b", 1, 1))
+	for _, userID := range []int64{1, 2} {
+		if err := f.Init(userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Transition(ctx, userID, "b"); err != nil {
+			t.Fatalf("expected user %d to have an own bucket, got %v", userID, err)
+		}
+	}
+}
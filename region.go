@@ -0,0 +1,99 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// TransitionRegion transitions the user to a new state in an orthogonal region.
+// Each region keeps its own state of the user, while callbacks and data storage are shared with FSM.
+// Seq and VisitCount are shared too, they count region transitions together with the main ones.
+// A region is seeded with the initial state on first access
+func (f *FSM[K, V]) TransitionRegion(ctx context.Context, userID int64, region string, stateID StateID, args ...any) error {
+	states := f.region(region)
+
+	_, _, err := f.seed(states, userID)
+	if err == nil {
+		err = f.transition(ctx, transitionRequest{
+			states: states,
+			userID: userID,
+			next:   toState(stateID),
+			args:   args,
+		})
+	}
+
+	return wrapError("transition region", userID, stateID, err)
+}
+
+// CurrentRegion returns the current state of the user in an orthogonal region
+func (f *FSM[K, V]) CurrentRegion(userID int64, region string) (StateID, error) {
+	stateID, _, err := f.seed(f.region(region), userID)
+
+	return stateID, wrapError("current region", userID, "", err)
+}
+
+// region returns the user state storage of a region, creating an in-memory one if it isn't set
+func (f *FSM[K, V]) region(region string) UserStateStorage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, ok := f.regions[region]
+	if !ok {
+		states = initialUserStateStorage()
+		f.regions[region] = states
+	}
+
+	return states
+}
+
+// regionStorages returns the region storages sorted by region name
+func (f *FSM[K, V]) regionStorages() ([]string, []UserStateStorage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := slices.Sorted(maps.Keys(f.regions))
+	storages := make([]UserStateStorage, len(names))
+	for i, name := range names {
+		storages[i] = f.regions[name]
+	}
+
+	return names, storages
+}
+
+// regionStates returns the user's states in the regions the user has entered
+func (f *FSM[K, V]) regionStates(userID int64) (map[string]StateID, error) {
+	names, storages := f.regionStorages()
+
+	var states map[string]StateID
+	for i, storage := range storages {
+		stateID, err := storage.Get(userID)
+		if errors.Is(err, ErrNoUserState) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user region state: %w", err)
+		}
+
+		if states == nil {
+			states = make(map[string]StateID)
+		}
+		states[names[i]] = stateID
+	}
+
+	return states, nil
+}
+
+// setRegionStates sets the user's states in the regions
+func (f *FSM[K, V]) setRegionStates(userID int64, states map[string]StateID) error {
+	for name, stateID := range states {
+		err := f.region(name).Set(userID, stateID)
+		if err != nil {
+			return fmt.Errorf("failed to set user region state: %w", err)
+		}
+	}
+
+	return nil
+}
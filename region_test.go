@@ -0,0 +1,97 @@
+package fsm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// closingStates is a user state storage recording Close calls
+type closingStates struct {
+	*userStateStorage
+	closed bool
+}
+
+func (s *closingStates) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRegions(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("start", nil)
+	if err := f.TransitionRegion(ctx, 1, "profile", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRegion(ctx, 1, "payment", "card"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRegion(ctx, 1, "profile", "age"); err != nil {
+		t.Fatal(err)
+	}
+
+	for region, expect := range map[string]StateID{"profile": "age", "payment": "card"} {
+		stateID, err := f.CurrentRegion(1, region)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stateID != expect {
+			t.Fatalf("expected %s in %s, got %s", expect, region, stateID)
+		}
+	}
+	if stateID, _ := f.Current(1); stateID != "start" {
+		t.Fatalf("expected main state start, got %s", stateID)
+	}
+}
+
+func TestRegionsAreCarried(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("start", nil)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TransitionRegion(ctx, 1, "profile", "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := New[string, int]("start", nil)
+	if err := f.Migrate(ctx, migrated, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stateID, _ := migrated.CurrentRegion(1, "profile"); stateID != "name" {
+		t.Fatalf("expected migrated region state name, got %s", stateID)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Dump(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New[string, int]("start", nil)
+	if err := loaded.Load(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if stateID, _ := loaded.CurrentRegion(1, "profile"); stateID != "name" {
+		t.Fatalf("expected loaded region state name, got %s", stateID)
+	}
+
+	if _, err := f.ResetAll(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stateID, _ := f.CurrentRegion(1, "profile"); stateID != "start" {
+		t.Fatalf("expected reset region state start, got %s", stateID)
+	}
+}
+
+func TestCloseClosesRegionStorages(t *testing.T) {
+	states := &closingStates{userStateStorage: initialUserStateStorage()}
+	f := New[string, int]("start", nil, WithRegionStorage[string, int]("profile", states))
+
+	if err := f.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !states.closed {
+		t.Fatal("expected region storage to be closed")
+	}
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"slices"
+	"sync"
+)
+
+// DefaultStateRegistry is a package level registry of states declared with MustState
+var DefaultStateRegistry = NewStateRegistry()
+
+// StateRegistry is a registry of declared states, it helps to catch transitions to mistyped states
+type StateRegistry struct {
+	mu     sync.RWMutex
+	states map[StateID]struct{}
+}
+
+// NewStateRegistry creates an empty state registry
+func NewStateRegistry() *StateRegistry {
+	return &StateRegistry{
+		states: make(map[StateID]struct{}),
+	}
+}
+
+// MustState registers a state in the registry and returns its StateID, it panics on an empty name
+func (r *StateRegistry) MustState(name string) StateID {
+	if name == "" {
+		panic("fsm: empty state name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stateID := StateID(name)
+	r.states[stateID] = struct{}{}
+
+	return stateID
+}
+
+// Registered checks whether the state is declared in the registry
+func (r *StateRegistry) Registered(stateID StateID) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.states[stateID]
+
+	return ok
+}
+
+// States returns all declared states sorted by name
+func (r *StateRegistry) States() []StateID {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]StateID, 0, len(r.states))
+	for stateID := range r.states {
+		states = append(states, stateID)
+	}
+
+	slices.Sort(states)
+
+	return states
+}
+
+// MustState registers a state in DefaultStateRegistry and returns its StateID, it panics on an empty name
+func MustState(name string) StateID {
+	return DefaultStateRegistry.MustState(name)
+}
@@ -0,0 +1,51 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestStateRegistryDetectsUnregisteredTransition(t *testing.T) {
+	r := NewStateRegistry()
+	start := r.MustState("start")
+	menu := r.MustState("menu")
+
+	var unregistered []StateID
+	f := New[string, int](start, nil, WithBeforeTransition[string, int](func(_ context.Context, _ int64, _, to StateID) error {
+		if !r.Registered(to) {
+			unregistered = append(unregistered, to)
+		}
+		return nil
+	}))
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stateID := range []StateID{menu, "mneu"} {
+		if err := f.Transition(context.Background(), 1, stateID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []StateID{"mneu"}; !reflect.DeepEqual(unregistered, want) {
+		t.Fatalf("expected %v to be detected, got %v", want, unregistered)
+	}
+	if want := []StateID{"menu", "start"}; !reflect.DeepEqual(r.States(), want) {
+		t.Fatalf("expected states %v, got %v", want, r.States())
+	}
+}
+
+func TestMustState(t *testing.T) {
+	stateID := MustState("registry_test")
+	if stateID != "registry_test" || !DefaultStateRegistry.Registered(stateID) {
+		t.Fatalf("expected %s to be registered in the default registry", stateID)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic on an empty name")
+		}
+	}()
+	MustState("")
+}
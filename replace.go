@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ReplaceUser replaces the user's state and data under the user's lock without firing callbacks,
+// keys missing in data and all lists are deleted, the locale is kept. If a write fails, the previous data, lists
+// and state are restored. The user's sequence number, visits, idempotency keys, proposal and rate limits are forgotten.
+// Data storage must implement DataEnumerator and DataClearer
+func (f *FSM[K, V]) ReplaceUser(userID int64, stateID StateID, data map[K]V) error {
+	unlock := f.locks.lock(userID)
+	defer unlock()
+
+	err := f.replaceUser(userID, stateID, data)
+	if err != nil {
+		return wrapError("replace user", userID, stateID, err)
+	}
+
+	f.forget(userID)
+
+	return nil
+}
+
+// replaceUser replaces the user's state and data, the user's lock must be held
+func (f *FSM[K, V]) replaceUser(userID int64, stateID StateID, data map[K]V) error {
+	previousData, err := f.all(userID)
+	if err != nil {
+		return err
+	}
+
+	previousLists, err := f.lists(userID)
+	if err != nil {
+		return err
+	}
+
+	previousState, err := f.userStates.Get(userID)
+	if err != nil && !errors.Is(err, ErrNoUserState) {
+		return fmt.Errorf("failed to get user state: %w", err)
+	}
+	hasState := err == nil
+
+	restore := func() error {
+		errs := []error{f.clear(userID)}
+		for key, value := range previousData {
+			if err := f.storage.Set(userID, key, value); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore user data: %w", err))
+			}
+		}
+		if err := f.setLists(userID, previousLists); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore user lists: %w", err))
+		}
+		if hasState {
+			if err := f.userStates.Set(userID, previousState); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore user state: %w", err))
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	err = f.clear(userID)
+	if err != nil {
+		return errors.Join(err, restore())
+	}
+
+	for key, value := range data {
+		err = f.set(userID, key, value)
+		if err != nil {
+			return errors.Join(err, restore())
+		}
+	}
+
+	err = f.userStates.Set(userID, stateID)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to set user state: %w", err), restore())
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// TransitionResult describes where the user ended up after a transition
+type TransitionResult struct {
+	// State is the state of the user after the transition and the transitions chained from its callback
+	State StateID
+	// Meta is the metadata of the state, see SetStateMeta
+	Meta map[string]any
+	// Routes maps inputs to target states of the input routes from the state, see AddInputRoute
+	Routes map[string]StateID
+}
+
+// TransitionWithResult transitions the user to a new state like Transition and returns the user's
+// resulting state with its metadata and input routes, so a handler can render the next step
+func (f *FSM[K, V]) TransitionWithResult(ctx context.Context, userID int64, stateID StateID, args ...any) (TransitionResult, error) {
+	err := f.Transition(ctx, userID, stateID, args...)
+	if err != nil {
+		return TransitionResult{}, err
+	}
+
+	current, err := f.userStates.Get(userID)
+	if err != nil {
+		return TransitionResult{}, wrapError("transition", userID, stateID, fmt.Errorf("failed to get user state: %w", err))
+	}
+
+	info := f.StateInfo(current)
+
+	return TransitionResult{
+		State:  current,
+		Meta:   info.Meta,
+		Routes: info.Routes,
+	}, nil
+}
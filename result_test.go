@@ -0,0 +1,36 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTransitionWithResult(t *testing.T) {
+	ctx := context.Background()
+
+	var f *FSM[string, int]
+	f = New[string, int]("start", map[StateID]Callback{
+		"redirect": func(ctx context.Context, _ ...any) error { return f.Transition(ctx, 1, "menu") },
+	})
+	f.SetStateMeta("menu", map[string]any{"title": "Menu"})
+	f.AddInputRoute("menu", "settings", "settings")
+	f.AddInputRoute("menu", "help", "help")
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := f.TransitionWithResult(ctx, 1, "redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := TransitionResult{
+		State:  "menu",
+		Meta:   map[string]any{"title": "Menu"},
+		Routes: map[string]StateID{"settings": "settings", "help": "help"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("expected %+v, got %+v", want, result)
+	}
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// routeKey is a key of an input route
+type routeKey struct {
+	from  StateID
+	input string
+}
+
+// errNoRoute is returned by the next function of RouteInput if the input has no route from the current state
+var errNoRoute = errors.New("no input route")
+
+// AddInputRoute adds a route transitioning the user from a state to another one on the input
+func (f *FSM[K, V]) AddInputRoute(from StateID, input string, to StateID) {
+	f.routes[routeKey{from: from, input: input}] = to
+}
+
+// RouteInput transitions the user by the route of the input from the user's current state.
+// The route is looked up under the user's lock, so a concurrent transition can't move the user in between.
+// It returns false if there is no such route
+func (f *FSM[K, V]) RouteInput(ctx context.Context, userID int64, input string, args ...any) (bool, error) {
+	_, _, err := f.seed(f.userStates, userID)
+	if err != nil {
+		return false, wrapError("route input", userID, "", err)
+	}
+
+	err = f.TransitionFunc(ctx, userID, func(current StateID) (StateID, error) {
+		to, ok := f.routes[routeKey{from: current, input: input}]
+		if !ok {
+			return "", errNoRoute
+		}
+
+		return to, nil
+	}, args...)
+	if errors.Is(err, errNoRoute) {
+		return false, nil
+	}
+
+	return true, err
+}
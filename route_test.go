@@ -0,0 +1,43 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRouteInput(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		input   string
+		matched bool
+		state   StateID
+	}{
+		{name: "matched", input: "yes", matched: true, state: "done"},
+		{name: "other route", input: "no", matched: true, state: "menu"},
+		{name: "unmatched", input: "maybe", matched: false, state: "ask"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("ask", nil)
+			f.AddInputRoute("ask", "yes", "done")
+			f.AddInputRoute("ask", "no", "menu")
+			f.AddInputRoute("menu", "maybe", "done")
+
+			matched, err := f.RouteInput(ctx, 1, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.matched {
+				t.Fatalf("expected matched %t, got %t", tt.matched, matched)
+			}
+			if got := mustState(t, f, 1); got != tt.state {
+				t.Fatalf("expected state %s, got %s", tt.state, got)
+			}
+			if m := f.Metrics(); m.Failures != 0 {
+				t.Fatalf("expected no failed transitions, got %d", m.Failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package fsm
+
+import "fmt"
+
+// Session is a typed per-user session object stored under a single data key
+type Session[S any, K comparable, V any] struct {
+	fsm *FSM[K, V]
+	key K
+}
+
+// NewSession creates a session stored in FSM data storage under the key.
+// V must be able to hold S, e.g. V is any or S itself.
+// Data storage must implement DataLookuper
+func NewSession[S any, K comparable, V any](f *FSM[K, V], key K) *Session[S, K, V] {
+	return &Session[S, K, V]{
+		fsm: f,
+		key: key,
+	}
+}
+
+// Get returns the user's session, false if the user has no session
+func (s *Session[S, K, V]) Get(userID int64) (S, bool, error) {
+	v, ok, err := s.fsm.lookup(userID, s.key)
+	if err != nil || !ok {
+		var empty S
+		return empty, false, wrapError("get session", userID, "", err)
+	}
+
+	session, ok, err := s.decode(v)
+	if err != nil {
+		return session, ok, wrapError("get session", userID, "", err)
+	}
+
+	return session, ok, nil
+}
+
+// Set sets the user's session
+func (s *Session[S, K, V]) Set(userID int64, session S) error {
+	v, err := s.encode(session)
+	if err != nil {
+		return wrapError("set session", userID, "", err)
+	}
+
+	return s.fsm.Set(userID, s.key, v)
+}
+
+// Update atomically replaces the user's session with the result of fn.
+// fn gets a zero S if the user has no session
+func (s *Session[S, K, V]) Update(userID int64, fn func(S) S) error {
+	unlock := s.fsm.locks.lock(userID)
+	defer unlock()
+
+	return wrapError("update session", userID, "", s.update(userID, fn))
+}
+
+// update replaces the user's session with the result of fn, the user's lock must be held
+func (s *Session[S, K, V]) update(userID int64, fn func(S) S) error {
+	v, ok, err := s.fsm.lookup(userID, s.key)
+	if err != nil {
+		return err
+	}
+
+	var session S
+	if ok {
+		session, _, err = s.decode(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	v, err = s.encode(fn(session))
+	if err != nil {
+		return err
+	}
+
+	return s.fsm.set(userID, s.key, v)
+}
+
+// decode converts a data storage value to a session
+func (s *Session[S, K, V]) decode(v V) (S, bool, error) {
+	session, ok := any(v).(S)
+	if !ok {
+		var empty S
+		return empty, false, fmt.Errorf("unexpected session type %T", v)
+	}
+
+	return session, true, nil
+}
+
+// encode converts a session to a data storage value
+func (s *Session[S, K, V]) encode(session S) (V, error) {
+	v, ok := any(session).(V)
+	if !ok {
+		var empty V
+		return empty, fmt.Errorf("session type %T can't be stored as data value", session)
+	}
+
+	return v, nil
+}
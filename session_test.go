@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"sync"
+	"testing"
+)
+
+// cart is a test session
+type cart struct {
+	Items int
+}
+
+func TestSession(t *testing.T) {
+	f := New[string, any]("a", nil)
+	s := NewSession[cart](f, "cart")
+
+	if _, ok, err := s.Get(1); err != nil || ok {
+		t.Fatalf("expected no session, got %t, %v", ok, err)
+	}
+
+	if err := s.Set(1, cart{Items: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := s.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || got.Items != 2 {
+		t.Fatalf("expected a cart with 2 items, got %+v (%t)", got, ok)
+	}
+}
+
+func TestSessionUpdateIsAtomic(t *testing.T) {
+	f := New[string, any]("a", nil)
+	s := NewSession[cart](f, "cart")
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Update(1, func(c cart) cart {
+				c.Items++
+				return c
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _, err := s.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Items != n {
+		t.Fatalf("expected %d items, got %d", n, got.Items)
+	}
+}
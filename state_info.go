@@ -0,0 +1,60 @@
+package fsm
+
+// StateInfo describes what is configured for a state
+type StateInfo struct {
+	// HasCallback tells whether the state has a callback, added directly or by a pattern
+	HasCallback bool
+	// TypedArgs tells whether the callback args are checked, see AddCallbackTyped
+	TypedArgs bool
+	// Observers is the number of transition observers of the state, global observers aren't counted
+	Observers int
+	// RateLimited tells whether the state's callback is rate limited, see WithStateRateLimit
+	RateLimited bool
+	// Deferred tells whether the state's callback is deferred, see AddDeferredCallback
+	Deferred bool
+	// Authorized tells whether entering the state requires authorization, see WithAuthorizedStates
+	Authorized bool
+	// Sticky tells whether the state is sticky, see WithStickyStates
+	Sticky bool
+	// Routes maps inputs to target states of the input routes from the state
+	Routes map[string]StateID
+	// RandomTargets are the targets of the random transition from the state
+	RandomTargets []StateID
+	// Meta is the state's metadata, see SetStateMeta
+	Meta map[string]any
+}
+
+// StateInfo returns what is configured for the state. The FSM doesn't restrict transitions,
+// so routes and random targets are the only targets it knows about
+func (f *FSM[K, V]) StateInfo(stateID StateID) StateInfo {
+	_, hasCallback := f.callback(stateID)
+	f.cbMu.RLock()
+	_, typedArgs := f.argTypes[stateID]
+	f.cbMu.RUnlock()
+	_, rateLimited := f.rateLimits[stateID]
+	_, authorized := f.authorizers[stateID]
+
+	info := StateInfo{
+		HasCallback: hasCallback,
+		TypedArgs:   typedArgs,
+		Observers:   len(f.observers[stateID]),
+		RateLimited: rateLimited,
+		Deferred:    f.isDeferred(stateID),
+		Authorized:  authorized,
+		Sticky:      f.sticky[stateID],
+		Routes:      make(map[string]StateID),
+		Meta:        f.stateMeta[stateID],
+	}
+
+	for key, to := range f.routes {
+		if key.from == stateID {
+			info.Routes[key.input] = to
+		}
+	}
+
+	for _, target := range f.randomTransitions[stateID] {
+		info.RandomTargets = append(info.RandomTargets, target.stateID)
+	}
+
+	return info
+}
@@ -0,0 +1,54 @@
+package fsm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestStateMeta(t *testing.T) {
+	f := New[string, int]("a", nil)
+	meta := map[string]any{"title": "Welcome", "button": "Start"}
+	f.SetStateMeta("a", meta)
+
+	got, ok := f.StateMeta("a")
+	if !ok || !reflect.DeepEqual(got, meta) {
+		t.Fatalf("expected meta %v, got %v (%t)", meta, got, ok)
+	}
+	if info := f.StateInfo("a"); !reflect.DeepEqual(info.Meta, meta) {
+		t.Fatalf("expected meta in state info, got %v", info.Meta)
+	}
+
+	if _, ok := f.StateMeta("b"); ok {
+		t.Fatal("expected no meta for a state without it")
+	}
+}
+
+func TestStateInfo(t *testing.T) {
+	f := New[string, int]("a", nil,
+		WithStateRateLimit[string, int]("b", 1, 1),
+		WithStickyStates[string, int]("b"),
+	)
+	f.AddCallbackTyped("b", []reflect.Type{reflect.TypeOf("")}, noop)
+	f.AddTransitionObserver("b", func(context.Context, int64, StateID, StateID, ...any) error { return nil })
+	f.AddInputRoute("b", "yes", "c")
+	f.AddRandomTransition("b", map[StateID]int{"c": 1})
+
+	got := f.StateInfo("b")
+	want := StateInfo{
+		HasCallback:   true,
+		TypedArgs:     true,
+		Observers:     1,
+		RateLimited:   true,
+		Sticky:        true,
+		Routes:        map[string]StateID{"yes": "c"},
+		RandomTargets: []StateID{"c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if info := f.StateInfo("c"); !reflect.DeepEqual(info, StateInfo{Routes: map[string]StateID{}}) {
+		t.Fatalf("expected nothing configured for c, got %+v", info)
+	}
+}
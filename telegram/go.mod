@@ -0,0 +1,10 @@
+module github.com/opasql/fsm/telegram
+
+go 1.23.0
+
+require (
+	github.com/go-telegram/bot v1.9.1
+	github.com/opasql/fsm v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/opasql/fsm => ../
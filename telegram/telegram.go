@@ -0,0 +1,57 @@
+// Package telegram is a thin adapter of FSM for github.com/go-telegram/bot updates.
+// It lives in a separate module, so the core package stays framework agnostic
+package telegram
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-telegram/bot/models"
+	"github.com/opasql/fsm"
+)
+
+// ErrNoUser is returned when the update has no sender
+var ErrNoUser = errors.New("update has no user")
+
+// UserID returns the ID of the user who sent the update
+func UserID(update *models.Update) (int64, bool) {
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From.ID, true
+	case update.EditedMessage != nil && update.EditedMessage.From != nil:
+		return update.EditedMessage.From.ID, true
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID, true
+	}
+
+	return 0, false
+}
+
+// ChatID returns the ID of the chat the update came from
+func ChatID(update *models.Update) (int64, bool) {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID, true
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat.ID, true
+	case update.CallbackQuery != nil && update.CallbackQuery.Message.Message != nil:
+		return update.CallbackQuery.Message.Message.Chat.ID, true
+	case update.CallbackQuery != nil && update.CallbackQuery.Message.InaccessibleMessage != nil:
+		return update.CallbackQuery.Message.InaccessibleMessage.Chat.ID, true
+	}
+
+	return 0, false
+}
+
+// TransitionUpdate transitions the sender of the update to a new state.
+// The callback of the state receives the chat ID of the update followed by args
+func TransitionUpdate[K comparable, V any](ctx context.Context, f *fsm.FSM[K, V], update *models.Update, stateID fsm.StateID, args ...any) error {
+	userID, ok := UserID(update)
+	if !ok {
+		return ErrNoUser
+	}
+
+	chatID, _ := ChatID(update)
+
+	return f.Transition(ctx, userID, stateID, append([]any{chatID}, args...)...)
+}
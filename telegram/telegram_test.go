@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+	"github.com/opasql/fsm"
+)
+
+func TestUserIDAndChatID(t *testing.T) {
+	tests := []struct {
+		name   string
+		update *models.Update
+		userID int64
+		chatID int64
+	}{
+		{
+			name:   "message",
+			update: &models.Update{Message: &models.Message{From: &models.User{ID: 1}, Chat: models.Chat{ID: 10}}},
+			userID: 1,
+			chatID: 10,
+		},
+		{
+			name:   "edited message",
+			update: &models.Update{EditedMessage: &models.Message{From: &models.User{ID: 2}, Chat: models.Chat{ID: 20}}},
+			userID: 2,
+			chatID: 20,
+		},
+		{
+			name: "callback query",
+			update: &models.Update{CallbackQuery: &models.CallbackQuery{
+				From:    models.User{ID: 3},
+				Message: models.MaybeInaccessibleMessage{Message: &models.Message{Chat: models.Chat{ID: 30}}},
+			}},
+			userID: 3,
+			chatID: 30,
+		},
+		{
+			name: "callback query of inaccessible message",
+			update: &models.Update{CallbackQuery: &models.CallbackQuery{
+				From:    models.User{ID: 4},
+				Message: models.MaybeInaccessibleMessage{InaccessibleMessage: &models.InaccessibleMessage{Chat: models.Chat{ID: 40}}},
+			}},
+			userID: 4,
+			chatID: 40,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, ok := UserID(tt.update)
+			if !ok || userID != tt.userID {
+				t.Fatalf("expected user %d, got %d (%t)", tt.userID, userID, ok)
+			}
+			chatID, ok := ChatID(tt.update)
+			if !ok || chatID != tt.chatID {
+				t.Fatalf("expected chat %d, got %d (%t)", tt.chatID, chatID, ok)
+			}
+		})
+	}
+}
+
+func TestTransitionUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	var got []any
+	f := fsm.New[string, int]("start", map[fsm.StateID]fsm.Callback{
+		"next": func(_ context.Context, args ...any) error {
+			got = args
+			return nil
+		},
+	})
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	update := &models.Update{Message: &models.Message{From: &models.User{ID: 1}, Chat: models.Chat{ID: 10}}}
+	if err := TransitionUpdate(ctx, f, update, "next", "a", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []any{int64(10), "a", 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected args %v, got %v", want, got)
+	}
+	stateID, err := f.Current(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stateID != "next" {
+		t.Fatalf("expected the sender to be transitioned, got %s", stateID)
+	}
+}
+
+func TestTransitionUpdateWithoutUser(t *testing.T) {
+	f := fsm.New[string, int]("start", nil)
+
+	err := TransitionUpdate(context.Background(), f, &models.Update{}, "next")
+	if !errors.Is(err, ErrNoUser) {
+		t.Fatalf("expected ErrNoUser, got %v", err)
+	}
+}
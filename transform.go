@@ -0,0 +1,236 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// ValueTransformer is a function transforming a value on its way to or from data storage
+type ValueTransformer[V any] func(value V) (V, error)
+
+// transformedDataStorage is a data storage encoding values on write and decoding them on read
+type transformedDataStorage[K comparable, V any] struct {
+	storage DataStorage[K, V]
+	encode  ValueTransformer[V]
+	decode  ValueTransformer[V]
+}
+
+// Set encodes the value and sets it to the wrapped storage
+func (t *transformedDataStorage[K, V]) Set(userID int64, key K, value V) error {
+	v, err := t.encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	return t.storage.Set(userID, key, v)
+}
+
+// Get gets the value from the wrapped storage and decodes it
+func (t *transformedDataStorage[K, V]) Get(userID int64, key K) (V, error) {
+	v, err := t.storage.Get(userID, key)
+	if err != nil {
+		return v, err
+	}
+
+	return t.decodeValue(v)
+}
+
+// Delete deletes the value from the wrapped storage
+func (t *transformedDataStorage[K, V]) Delete(userID int64, key K) error {
+	return t.storage.Delete(userID, key)
+}
+
+// All returns all decoded user's data from the wrapped storage if it implements DataEnumerator
+func (t *transformedDataStorage[K, V]) All(userID int64) (map[K]V, error) {
+	enumerator, ok := t.storage.(DataEnumerator[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't enumerate user data", ErrNotSupported)
+	}
+
+	data, err := enumerator.All(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make(map[K]V, len(data))
+	for key, value := range data {
+		decoded[key], err = t.decodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decoded, nil
+}
+
+// Lookup gets the decoded value from the wrapped storage if it implements DataLookuper
+func (t *transformedDataStorage[K, V]) Lookup(userID int64, key K) (V, bool, error) {
+	lookuper, ok := t.storage.(DataLookuper[K, V])
+	if !ok {
+		var empty V
+		return empty, false, fmt.Errorf("%w: data storage can't look up keys", ErrNotSupported)
+	}
+
+	v, found, err := lookuper.Lookup(userID, key)
+	if err != nil || !found {
+		return v, found, err
+	}
+
+	v, err = t.decodeValue(v)
+
+	return v, err == nil, err
+}
+
+// GetAndDelete gets and deletes the value in the wrapped storage if it implements DataGetDeleter
+func (t *transformedDataStorage[K, V]) GetAndDelete(userID int64, key K) (V, bool, error) {
+	getDeleter, ok := t.storage.(DataGetDeleter[K, V])
+	if !ok {
+		var empty V
+		return empty, false, fmt.Errorf("%w: data storage can't get and delete keys", ErrNotSupported)
+	}
+
+	v, found, err := getDeleter.GetAndDelete(userID, key)
+	if err != nil || !found {
+		return v, found, err
+	}
+
+	v, err = t.decodeValue(v)
+
+	return v, err == nil, err
+}
+
+// Append encodes the value and appends it to user's list in the wrapped storage if it implements DataLister
+func (t *transformedDataStorage[K, V]) Append(userID int64, key K, value V) error {
+	lister, ok := t.storage.(DataLister[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	v, err := t.encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	return lister.Append(userID, key, v)
+}
+
+// List returns decoded user's list from the wrapped storage if it implements DataLister
+func (t *transformedDataStorage[K, V]) List(userID int64, key K) ([]V, error) {
+	lister, ok := t.storage.(DataLister[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	list, err := lister.List(userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.decodeList(list)
+}
+
+// Lists returns decoded user's lists from the wrapped storage if it implements DataLister
+func (t *transformedDataStorage[K, V]) Lists(userID int64) (map[K][]V, error) {
+	lister, ok := t.storage.(DataLister[K, V])
+	if !ok {
+		return nil, fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	lists, err := lister.Lists(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make(map[K][]V, len(lists))
+	for key, list := range lists {
+		decoded[key], err = t.decodeList(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decoded, nil
+}
+
+// SetList encodes the values and replaces user's list in the wrapped storage if it implements DataLister
+func (t *transformedDataStorage[K, V]) SetList(userID int64, key K, list []V) error {
+	lister, ok := t.storage.(DataLister[K, V])
+	if !ok {
+		return fmt.Errorf("%w: data storage can't keep lists", ErrNotSupported)
+	}
+
+	encoded := make([]V, len(list))
+	for i, value := range list {
+		v, err := t.encode(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode value: %w", err)
+		}
+		encoded[i] = v
+	}
+
+	return lister.SetList(userID, key, encoded)
+}
+
+// decodeList decodes the values of a list
+func (t *transformedDataStorage[K, V]) decodeList(list []V) ([]V, error) {
+	decoded := make([]V, len(list))
+	for i, value := range list {
+		var err error
+		decoded[i], err = t.decodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decoded, nil
+}
+
+// Count counts user's keys in the wrapped storage if it implements DataCounter
+func (t *transformedDataStorage[K, V]) Count(userID int64) (int, error) {
+	counter, ok := t.storage.(DataCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: data storage can't count user data", ErrNotSupported)
+	}
+
+	return counter.Count(userID)
+}
+
+// Clear deletes all user's data from the wrapped storage if it implements DataClearer
+func (t *transformedDataStorage[K, V]) Clear(userID int64) error {
+	clearer, ok := t.storage.(DataClearer)
+	if !ok {
+		return fmt.Errorf("%w: data storage can't clear user data", ErrNotSupported)
+	}
+
+	return clearer.Clear(userID)
+}
+
+// Flush flushes the wrapped storage if it implements Flusher
+func (t *transformedDataStorage[K, V]) Flush() error {
+	return flush(t.storage)
+}
+
+// Close closes the wrapped storage if it implements io.Closer
+func (t *transformedDataStorage[K, V]) Close() error {
+	return closeStorage(t.storage)
+}
+
+// Ping pings the wrapped storage if it implements Pinger
+func (t *transformedDataStorage[K, V]) Ping(ctx context.Context) error {
+	return ping(ctx, t.storage)
+}
+
+// Warm warms the wrapped storage if it implements Warmer
+func (t *transformedDataStorage[K, V]) Warm(ctx context.Context, userIDs []int64) error {
+	return warm(ctx, t.storage, userIDs)
+}
+
+// decodeValue decodes the value read from the wrapped storage
+func (t *transformedDataStorage[K, V]) decodeValue(value V) (V, error) {
+	v, err := t.decode(value)
+	if err != nil {
+		return v, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,46 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValueTransformer(t *testing.T) {
+	storage := initialDataStorage[string, int]()
+	f := New[string, int]("a", nil,
+		WithDataStorage[string, int](storage),
+		WithValueTransformer[string, int](
+			func(v int) (int, error) { return v + 100, nil },
+			func(v int) (int, error) { return v - 100, nil },
+		),
+	)
+
+	if err := f.Set(1, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := storage.Get(1, "k"); err != nil || v != 101 {
+		t.Fatalf("expected storage to hold 101, got %d, %v", v, err)
+	}
+	if v, err := f.Get(1, "k"); err != nil || v != 1 {
+		t.Fatalf("expected callers to see 1, got %d, %v", v, err)
+	}
+}
+
+func TestValueTransformerErrors(t *testing.T) {
+	fail := func(int) (int, error) { return 0, errTest }
+	pass := func(v int) (int, error) { return v, nil }
+
+	encodeFails := New[string, int]("a", nil, WithValueTransformer[string, int](fail, pass))
+	if err := encodeFails.Set(1, "k", 1); !errors.Is(err, errTest) {
+		t.Fatalf("expected encode error, got %v", err)
+	}
+
+	decodeFails := New[string, int]("a", nil, WithValueTransformer[string, int](pass, fail))
+	if err := decodeFails.Set(1, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeFails.Get(1, "k"); !errors.Is(err, errTest) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestTransitionFuncBranchesOnCurrent(t *testing.T) {
+	ctx := context.Background()
+
+	next := func(current StateID) (StateID, error) {
+		if current == "guest" {
+			return "signup", nil
+		}
+		return "home", nil
+	}
+
+	tests := []struct {
+		name    string
+		current StateID
+		state   StateID
+	}{
+		{name: "guest", current: "guest", state: "signup"},
+		{name: "member", current: "member", state: "home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New[string, int]("guest", nil)
+			if err := f.userStates.Set(1, tt.current); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.TransitionFunc(ctx, 1, next); err != nil {
+				t.Fatal(err)
+			}
+			if got := mustState(t, f, 1); got != tt.state {
+				t.Fatalf("expected state %s, got %s", tt.state, got)
+			}
+		})
+	}
+}
+
+func TestTransitionFuncNextError(t *testing.T) {
+	f := New[string, int]("a", nil)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.TransitionFunc(context.Background(), 1, func(StateID) (StateID, error) { return "", errTest })
+	if !errors.Is(err, errTest) {
+		t.Fatalf("expected next error, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "a" {
+		t.Fatalf("expected state a, got %s", got)
+	}
+}
+
+func TestTransitionFuncIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	f := New[string, int]("0", nil)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	increment := func(current StateID) (StateID, error) {
+		n, err := strconv.Atoi(string(current))
+		if err != nil {
+			return "", err
+		}
+		return StateID(strconv.Itoa(n + 1)), nil
+	}
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.TransitionFunc(ctx, 1, increment); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := mustState(t, f, 1); got != StateID(strconv.Itoa(n)) {
+		t.Fatalf("expected state %d, got %s", n, got)
+	}
+}
@@ -7,14 +7,16 @@ import (
 
 // userStateStorage is a type for default user's state storage
 type userStateStorage struct {
-	mu      sync.RWMutex
-	Storage map[int64]StateID
+	mu       sync.RWMutex
+	Storage  map[int64]StateID
+	versions map[int64]uint64
 }
 
 // initialUserStateStorage creates in memory storage for user's state
 func initialUserStateStorage() *userStateStorage {
 	return &userStateStorage{
-		Storage: make(map[int64]StateID),
+		Storage:  make(map[int64]StateID),
+		versions: make(map[int64]uint64),
 	}
 }
 
@@ -24,6 +26,7 @@ func (u *userStateStorage) Set(userID int64, stateID StateID) error {
 	defer u.mu.Unlock()
 
 	u.Storage[userID] = stateID
+	u.versions[userID]++
 
 	return nil
 }
@@ -50,3 +53,44 @@ func (u *userStateStorage) Get(userID int64) (StateID, error) {
 
 	return s, nil
 }
+
+// Users returns all users from state storage
+func (u *userStateStorage) Users() ([]int64, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	userIDs := make([]int64, 0, len(u.Storage))
+	for userID := range u.Storage {
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// GetVersion gets user's state with its version from state storage
+func (u *userStateStorage) GetVersion(userID int64) (StateID, uint64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s, ok := u.Storage[userID]
+	if !ok {
+		return "", 0, fmt.Errorf("%w: userID: %d", ErrNoUserState, userID)
+	}
+
+	return s, u.versions[userID], nil
+}
+
+// SetIfVersion sets user's state to state storage if its version matches expectedVersion
+func (u *userStateStorage) SetIfVersion(userID int64, stateID StateID, expectedVersion uint64) (uint64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.versions[userID] != expectedVersion {
+		return 0, fmt.Errorf("%w: userID: %d", ErrConcurrentModification, userID)
+	}
+
+	u.Storage[userID] = stateID
+	u.versions[userID]++
+
+	return u.versions[userID], nil
+}
@@ -0,0 +1,14 @@
+package fsm
+
+import "fmt"
+
+// Validate checks the FSM configuration for common setup bugs. It returns ErrNoInitialCallback
+// if the initial state has no callback, so new users are seeded without a prompt,
+// unless WithoutInitialCallback is set. States computed by WithInitialStateFunc aren't checked
+func (f *FSM[K, V]) Validate() error {
+	if _, ok := f.callback(f.initialStateID); !ok && !f.noInitialCallback {
+		return fmt.Errorf("%w: state: %s", ErrNoInitialCallback, f.initialStateID)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		fsm  *FSM[string, int]
+		want error
+	}{
+		{name: "no initial callback", fsm: New[string, int]("a", nil), want: ErrNoInitialCallback},
+		{name: "initial callback", fsm: New[string, int]("a", map[StateID]Callback{"a": noop})},
+		{name: "acknowledged", fsm: New[string, int]("a", nil, WithoutInitialCallback[string, int]())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fsm.Validate()
+			if tt.want == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidatePatternCallback(t *testing.T) {
+	f := New[string, int]("step1", nil)
+	if err := f.AddCallbackPattern("step*", noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Validate(); err != nil {
+		t.Fatalf("expected a pattern callback to count, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package fsm
+
+import "fmt"
+
+// VersionedUserStateStorage is an optional interface for user state storages
+// supporting optimistic concurrency control between several FSM instances
+type VersionedUserStateStorage interface {
+	// GetVersion returns the user's state with its version
+	GetVersion(userID int64) (StateID, uint64, error)
+	// SetIfVersion sets the user's state if its version is still expectedVersion and returns the new version,
+	// otherwise it returns ErrConcurrentModification
+	SetIfVersion(userID int64, stateID StateID, expectedVersion uint64) (uint64, error)
+}
+
+// getState returns the user's state and its version, the version is always 0 for unversioned storages
+func getState(states UserStateStorage, userID int64) (StateID, uint64, error) {
+	versioned, ok := states.(VersionedUserStateStorage)
+	if !ok {
+		stateID, err := states.Get(userID)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to get user state: %w", err)
+		}
+
+		return stateID, 0, nil
+	}
+
+	stateID, version, err := versioned.GetVersion(userID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get user state: %w", err)
+	}
+
+	return stateID, version, nil
+}
+
+// setState sets the user's state, for versioned storages only if the state wasn't changed since version
+func setState(states UserStateStorage, userID int64, stateID StateID, version uint64) (uint64, error) {
+	versioned, ok := states.(VersionedUserStateStorage)
+	if !ok {
+		err := states.Set(userID, stateID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to set user state: %w", err)
+		}
+
+		return 0, nil
+	}
+
+	version, err := versioned.SetIfVersion(userID, stateID, version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set user state: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,32 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRacingFSMsOnSharedVersionedStorage(t *testing.T) {
+	ctx := context.Background()
+	states := initialUserStateStorage()
+
+	other := New[string, int]("a", nil, WithUserStateStorage[string, int](states))
+	f := New[string, int]("a", nil,
+		WithUserStateStorage[string, int](states),
+		// the other FSM doesn't share the lock, so it moves the user between the read and the write
+		WithBeforeTransition[string, int](func(ctx context.Context, userID int64, _, _ StateID) error {
+			return other.Transition(ctx, userID, "c")
+		}),
+	)
+	if err := f.Init(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.Transition(ctx, 1, "b")
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	if got := mustState(t, f, 1); got != "c" {
+		t.Fatalf("expected state c, got %s", got)
+	}
+}